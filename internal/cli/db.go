@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zigai/zgod/internal/config"
+	"github.com/zigai/zgod/internal/db"
+	"github.com/zigai/zgod/internal/paths"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Maintain the history database",
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+var dbCompactCmd = &cobra.Command{
+	Use:          "compact",
+	Short:        "Collapse consecutive duplicate commands into single rows",
+	Long:         "Collapse runs of the same command in the same session and directory into one row with a run counter.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE:         runDBCompact,
+}
+
+func registerDBCommand() {
+	dbCmd.AddCommand(dbCompactCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+func runDBCompact(cmd *cobra.Command, args []string) error {
+	database, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = database.Close() }()
+
+	removed, err := db.NewHistoryRepo(database).CompactDuplicates()
+	if err != nil {
+		return fmt.Errorf("compacting history: %w", err)
+	}
+
+	cmd.Printf("Compact complete: removed=%d\n", removed)
+
+	return nil
+}
+
+// openConfiguredDatabase opens the history database named by the config.
+func openConfiguredDatabase() (*sql.DB, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	if err = paths.EnsureDirs(); err != nil {
+		return nil, fmt.Errorf("ensuring directories: %w", err)
+	}
+
+	dbPath, err := cfg.DatabasePath()
+	if err != nil {
+		return nil, fmt.Errorf("resolving database path: %w", err)
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	return database, nil
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zigai/zgod/internal/db"
+	"github.com/zigai/zgod/internal/histfile"
+	"github.com/zigai/zgod/internal/history"
+	"github.com/zigai/zgod/internal/paths"
+)
+
+var (
+	errUnsupportedExportFormat = errors.New("unsupported export format")
+	errInvalidFailFilter       = errors.New("invalid fail filter")
+)
+
+var exportFormats = []string{
+	importFormatJSONL,
+	importFormatBash,
+	importFormatZsh,
+	importFormatFish,
+	importFormatPSReadLine,
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write history to stdout",
+	Long: "Write history to stdout as JSONL, one entry per line, for use with `zgod import -`, " +
+		"or as a native shell history file. Supported formats: " + strings.Join(exportFormats, ", ") + ".",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE:         runExport,
+}
+
+func registerExportCommand() {
+	exportCmd.Flags().String("format", importFormatJSONL, "Output format: "+strings.Join(exportFormats, ", "))
+	exportCmd.Flags().String("since", "", "Only export entries at or after this time (e.g. 30d, 2024-01-31)")
+	exportCmd.Flags().String("until", "", "Only export entries before this time (e.g. 7d, 2024-01-31)")
+	exportCmd.Flags().String("host", "", "Only export entries recorded on this hostname")
+	exportCmd.Flags().String("directory", "", "Only export entries run in this directory")
+	exportCmd.Flags().String("session", "", "Only export entries from this session ID")
+	exportCmd.Flags().String("fail-filter", "include", "Failed commands: include, exclude, or only")
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if !slices.Contains(exportFormats, format) {
+		return fmt.Errorf(
+			"%w %q: must be one of %s",
+			errUnsupportedExportFormat,
+			format,
+			strings.Join(exportFormats, ", "),
+		)
+	}
+
+	filter, err := readExportFilter(cmd, time.Now())
+	if err != nil {
+		return err
+	}
+
+	database, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = database.Close() }()
+
+	out := bufio.NewWriter(cmd.OutOrStdout())
+
+	if err = db.NewHistoryRepo(database).Export(filter, newExportWriter(format, out)); err != nil {
+		return fmt.Errorf("exporting history: %w", err)
+	}
+
+	if err = out.Flush(); err != nil {
+		return fmt.Errorf("flushing export output: %w", err)
+	}
+
+	return nil
+}
+
+func newExportWriter(format string, w io.Writer) func(db.HistoryEntry) error {
+	switch format {
+	case importFormatBash:
+		return histfile.NewBashWriter(w).Write
+	case importFormatZsh:
+		return histfile.NewZshWriter(w).Write
+	case importFormatFish:
+		return histfile.NewFishWriter(w).Write
+	case importFormatPSReadLine:
+		return histfile.NewPSReadLineWriter(w).Write
+	default:
+		return histfile.NewJSONLWriter(w).Write
+	}
+}
+
+func readExportFilter(cmd *cobra.Command, now time.Time) (db.ExportFilter, error) {
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	host, _ := cmd.Flags().GetString("host")
+	directory, _ := cmd.Flags().GetString("directory")
+	session, _ := cmd.Flags().GetString("session")
+	failFilterValue, _ := cmd.Flags().GetString("fail-filter")
+
+	sinceMs, err := history.ParseTimeBound(since, now)
+	if err != nil {
+		return db.ExportFilter{}, fmt.Errorf("parsing --since: %w", err)
+	}
+
+	untilMs, err := history.ParseTimeBound(until, now)
+	if err != nil {
+		return db.ExportFilter{}, fmt.Errorf("parsing --until: %w", err)
+	}
+
+	failFilter, ok := db.ParseFailFilterMode(failFilterValue)
+	if !ok {
+		return db.ExportFilter{}, fmt.Errorf(
+			"%w %q: must be include, exclude, or only",
+			errInvalidFailFilter,
+			failFilterValue,
+		)
+	}
+
+	if directory != "" {
+		if directory, err = resolveDirectoryFlag(directory); err != nil {
+			return db.ExportFilter{}, err
+		}
+	}
+
+	return db.ExportFilter{
+		SinceMs:    sinceMs,
+		UntilMs:    untilMs,
+		Hostname:   host,
+		Directory:  directory,
+		SessionID:  session,
+		FailFilter: failFilter,
+	}, nil
+}
+
+func resolveDirectoryFlag(directory string) (string, error) {
+	expanded, err := paths.ExpandTilde(directory)
+	if err != nil {
+		return "", fmt.Errorf("expanding directory %q: %w", directory, err)
+	}
+
+	absolute, err := filepath.Abs(expanded)
+	if err != nil {
+		return "", fmt.Errorf("building absolute path for %q: %w", directory, err)
+	}
+
+	return absolute, nil
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadImportStreamParsesJSONLAndRejectsDatabaseFormats(t *testing.T) {
+	input := `{"v":1,"ts_ms":1000,"command":"git status","hostname":"remote","run_count":1}` + "\n"
+
+	source, err := readImportStream(importFormatJSONL, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("readImportStream() error: %v", err)
+	}
+
+	records := collectImportSource(t, source)
+	if len(records) != 1 || records[0].entry.Hostname != "remote" || records[0].entry.Command != "git status" {
+		t.Fatalf("readImportStream() = %+v, want one git status entry from host remote", records)
+	}
+
+	if _, err = readImportStream(importFormatAtuin, strings.NewReader("")); !errors.Is(err, errImportStdinFormat) {
+		t.Fatalf("readImportStream(atuin) error = %v, want %v", err, errImportStdinFormat)
+	}
+}
+
+func TestReadImportStreamDecodesJSONLAsItIsImported(t *testing.T) {
+	errPipe := errors.New("pipe closed")
+	r := io.MultiReader(
+		strings.NewReader(`{"v":1,"ts_ms":1000,"command":"ls"}`+"\n"),
+		iotest.ErrReader(errPipe),
+	)
+
+	source, err := readImportStream(importFormatJSONL, r)
+	if err != nil {
+		t.Fatalf("readImportStream() error: %v", err)
+	}
+
+	var got []string
+
+	err = source(func(record importRecord) error {
+		got = append(got, record.entry.Command)
+		return nil
+	})
+	if !errors.Is(err, errPipe) {
+		t.Fatalf("source() error = %v, want %v", err, errPipe)
+	}
+
+	if len(got) != 1 || got[0] != "ls" {
+		t.Fatalf("source() delivered %q before the read error, want [ls]", got)
+	}
+}
+
+func collectImportSource(t *testing.T, source importSource) []importRecord {
+	t.Helper()
+
+	var records []importRecord
+
+	err := source(func(record importRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading import source: %v", err)
+	}
+
+	return records
+}
@@ -8,24 +8,33 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/zigai/zgod/internal/config"
 	"github.com/zigai/zgod/internal/db"
+	"github.com/zigai/zgod/internal/history"
 	"github.com/zigai/zgod/internal/paths"
 )
 
 var (
 	errImportSourceEqualsTarget = errors.New("source database must be different from target database")
-	errImportSourceRequired     = errors.New("source database path is required")
-	errImportSourceNotFound     = errors.New("source database does not exist")
+	errImportSourceRequired     = errors.New("source path is required")
+	errImportSourceNotFound     = errors.New("import source does not exist")
+	errUnsupportedImportFormat  = errors.New("unsupported import format")
+	errImportStdinFormat        = errors.New("format cannot be read from stdin")
 )
 
+// importStdinSource is the source path that reads history from stdin.
+const importStdinSource = "-"
+
 var importCmd = &cobra.Command{
-	Use:          "import <source-db-path>",
-	Short:        "Import history from another SQLite database",
+	Use:          "import <source-path>",
+	Short:        "Import history from another zgod database, shell history file, or history tool",
+	Long:         "Import history from another zgod SQLite database, a shell history file, or another history tool's database. Supported formats: " + importFormatList + ". Use - as the source path to read JSONL (or --format) from stdin.",
 	SilenceUsage: true,
 	Args:         cobra.ExactArgs(1),
 	RunE:         runImport,
@@ -34,23 +43,41 @@ var importCmd = &cobra.Command{
 type importOptions struct {
 	includeFailed       bool
 	includeMissingPaths bool
+	pathMappings        pathMappings
+	sinceMs             int64
+	untilMs             int64
+	host                string
+	filter              *history.Filter
+	dryRun              bool
 }
 
 type importSummary struct {
 	total              int
 	imported           int
 	skippedFailed      int
+	skippedFiltered    int
 	skippedMissingPath int
 	skippedDuplicate   int
 }
 
 func registerImportCommand() {
+	importCmd.Flags().String("format", importFormatZgod, "Source format: "+importFormatList)
 	importCmd.Flags().Bool("include-failed", false, "Include commands with non-zero exit code")
 	importCmd.Flags().Bool(
 		"include-missing-paths",
 		false,
 		"Include commands that reference paths missing on this machine",
 	)
+	importCmd.Flags().StringArray(
+		"map-path",
+		nil,
+		"Rewrite paths under OLD to NEW in directories and commands (OLD=NEW, repeatable)",
+	)
+	importCmd.Flags().String("since", "", "Only import entries at or after this time (e.g. 1y, 2024-01-31)")
+	importCmd.Flags().String("until", "", "Only import entries before this time (e.g. 30d, 2024-01-31)")
+	importCmd.Flags().String("host", "", "Only import entries recorded on this hostname")
+	importCmd.Flags().Bool("apply-filters", false, "Skip entries excluded by the [filters] config")
+	importCmd.Flags().Bool("dry-run", false, "Print the import summary without writing any entries")
 	rootCmd.AddCommand(importCmd)
 }
 
@@ -60,11 +87,24 @@ func runImport(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	format, err := readImportFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 && args[0] == importStdinSource {
+		return runStdinImport(cmd, format, opts)
+	}
+
 	sourcePath, targetPath, err := resolveImportPaths(args)
 	if err != nil {
 		return err
 	}
 
+	if format != importFormatZgod {
+		return runFormatImport(cmd, format, sourcePath, targetPath, opts)
+	}
+
 	targetDB, sourceDB, err := openImportDatabases(targetPath, sourcePath)
 	if err != nil {
 		return err
@@ -82,7 +122,64 @@ func runImport(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	printImportSummary(cmd, summary)
+	printImportSummary(cmd, summary, opts.dryRun)
+
+	return nil
+}
+
+func runFormatImport(
+	cmd *cobra.Command,
+	format string,
+	sourcePath string,
+	targetPath string,
+	opts importOptions,
+) error {
+	sourceRecords, err := readImportSource(format, sourcePath)
+	if err != nil {
+		return err
+	}
+
+	return importSourceInto(cmd, targetPath, recordsSource(sourceRecords), opts)
+}
+
+func runStdinImport(cmd *cobra.Command, format string, opts importOptions) error {
+	// A bare `zgod import -` reads the JSONL written by `zgod export`.
+	if !cmd.Flags().Changed("format") {
+		format = importFormatJSONL
+	}
+
+	source, err := readImportStream(format, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	targetPath, err := resolveTargetImportPath()
+	if err != nil {
+		return err
+	}
+
+	return importSourceInto(cmd, targetPath, source, opts)
+}
+
+func importSourceInto(
+	cmd *cobra.Command,
+	targetPath string,
+	source importSource,
+	opts importOptions,
+) error {
+	targetDB, err := openImportTarget(targetPath)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = targetDB.Close() }()
+
+	summary, err := importFromSource(targetDB, source, opts)
+	if err != nil {
+		return err
+	}
+
+	printImportSummary(cmd, summary, opts.dryRun)
 
 	return nil
 }
@@ -129,14 +226,23 @@ func resolveTargetImportPath() (string, error) {
 	return targetPath, nil
 }
 
-func openImportDatabases(targetPath string, sourcePath string) (*sql.DB, *sql.DB, error) {
+func openImportTarget(targetPath string) (*sql.DB, error) {
 	if err := paths.EnsureDirs(); err != nil {
-		return nil, nil, fmt.Errorf("ensuring directories: %w", err)
+		return nil, fmt.Errorf("ensuring directories: %w", err)
 	}
 
 	targetDB, err := db.Open(targetPath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("opening target database: %w", err)
+		return nil, fmt.Errorf("opening target database: %w", err)
+	}
+
+	return targetDB, nil
+}
+
+func openImportDatabases(targetPath string, sourcePath string) (*sql.DB, *sql.DB, error) {
+	targetDB, err := openImportTarget(targetPath)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	sourceDB, err := db.OpenReadOnly(sourcePath)
@@ -171,12 +277,19 @@ func listSourceEntries(sourceDB *sql.DB) ([]db.HistoryEntry, error) {
 	return sourceEntries, nil
 }
 
-func printImportSummary(cmd *cobra.Command, summary importSummary) {
+func printImportSummary(cmd *cobra.Command, summary importSummary, dryRun bool) {
+	heading := "Import complete"
+	if dryRun {
+		heading = "Import dry run (nothing written)"
+	}
+
 	cmd.Printf(
-		"Import complete: total=%d imported=%d skipped_failed=%d skipped_missing_paths=%d skipped_duplicates=%d\n",
+		"%s: total=%d imported=%d skipped_failed=%d skipped_filtered=%d skipped_missing_paths=%d skipped_duplicates=%d\n",
+		heading,
 		summary.total,
 		summary.imported,
 		summary.skippedFailed,
+		summary.skippedFiltered,
 		summary.skippedMissingPath,
 		summary.skippedDuplicate,
 	)
@@ -193,12 +306,81 @@ func readImportOptions(cmd *cobra.Command) (importOptions, error) {
 		return importOptions{}, fmt.Errorf("reading --include-missing-paths flag: %w", err)
 	}
 
+	mappingRules, _ := cmd.Flags().GetStringArray("map-path")
+
+	mappings, err := parsePathMappings(mappingRules)
+	if err != nil {
+		return importOptions{}, fmt.Errorf("parsing --map-path: %w", err)
+	}
+
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	host, _ := cmd.Flags().GetString("host")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	now := time.Now()
+
+	sinceMs, err := history.ParseTimeBound(since, now)
+	if err != nil {
+		return importOptions{}, fmt.Errorf("parsing --since: %w", err)
+	}
+
+	untilMs, err := history.ParseTimeBound(until, now)
+	if err != nil {
+		return importOptions{}, fmt.Errorf("parsing --until: %w", err)
+	}
+
+	filter, err := readImportFilter(cmd)
+	if err != nil {
+		return importOptions{}, err
+	}
+
 	return importOptions{
 		includeFailed:       includeFailed,
 		includeMissingPaths: includeMissingPaths,
+		pathMappings:        mappings,
+		sinceMs:             sinceMs,
+		untilMs:             untilMs,
+		host:                host,
+		filter:              filter,
+		dryRun:              dryRun,
 	}, nil
 }
 
+// readImportFilter returns the configured record filter when --apply-filters
+// is set, and nil otherwise.
+func readImportFilter(cmd *cobra.Command) (*history.Filter, error) {
+	applyFilters, _ := cmd.Flags().GetBool("apply-filters")
+	if !applyFilters {
+		return nil, nil //nolint:nilnil // a nil filter means filtering is disabled
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	filter, err := history.NewFilter(cfg.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("building filter: %w", err)
+	}
+
+	return filter, nil
+}
+
+func readImportFormat(cmd *cobra.Command) (string, error) {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return "", fmt.Errorf("reading --format flag: %w", err)
+	}
+
+	if !slices.Contains(importFormats, format) {
+		return "", fmt.Errorf("%w %q: must be one of %s", errUnsupportedImportFormat, format, importFormatList)
+	}
+
+	return format, nil
+}
+
 func resolveExistingPath(args []string) (string, error) {
 	if len(args) == 0 || args[0] == "" {
 		return "", errImportSourceRequired
@@ -206,12 +388,12 @@ func resolveExistingPath(args []string) (string, error) {
 
 	path, err := paths.ExpandTilde(args[0])
 	if err != nil {
-		return "", fmt.Errorf("expanding source database path %q: %w", args[0], err)
+		return "", fmt.Errorf("expanding source path %q: %w", args[0], err)
 	}
 
 	path, err = normalizePath(path)
 	if err != nil {
-		return "", fmt.Errorf("normalizing source database path: %w", err)
+		return "", fmt.Errorf("normalizing source path: %w", err)
 	}
 
 	if _, err = os.Stat(path); err != nil {
@@ -227,11 +409,11 @@ func resolveExistingPath(args []string) (string, error) {
 
 func wrapImportSourceAccessError(action string, sourcePath string, err error) error {
 	if !isPermissionDeniedError(err) {
-		return fmt.Errorf("%s source database %q: %w", action, sourcePath, err)
+		return fmt.Errorf("%s import source %q: %w", action, sourcePath, err)
 	}
 
 	return fmt.Errorf(
-		"%s source database %q: permission denied; %s: %w",
+		"%s import source %q: permission denied; %s: %w",
 		action,
 		sourcePath,
 		importSourcePermissionHint(),
@@ -295,10 +477,64 @@ func pathsReferToSameFile(sourcePath string, targetPath string) (bool, error) {
 	return sourcePath == targetPath, nil
 }
 
+// importRecord is an entry to import. Sources that record the paths a command
+// used, such as fish, set recordedPaths so the missing-path check can use them
+// instead of guessing from the command line.
+type importRecord struct {
+	entry         db.HistoryEntry
+	paths         []string
+	recordedPaths bool
+}
+
 func importHistoryEntries(
 	targetDB *sql.DB,
 	entries []db.HistoryEntry,
 	opts importOptions,
+) (importSummary, error) {
+	return importHistoryRecords(targetDB, recordsFromEntries(entries), opts)
+}
+
+func recordsFromEntries(entries []db.HistoryEntry) []importRecord {
+	records := make([]importRecord, len(entries))
+	for i, entry := range entries {
+		records[i] = recordFromEntry(entry)
+	}
+
+	return records
+}
+
+func recordFromEntry(entry db.HistoryEntry) importRecord {
+	return importRecord{entry: entry, paths: nil, recordedPaths: false}
+}
+
+// importSource passes the records to import to add one at a time, so streamed
+// input is inserted as it is read.
+type importSource func(add func(importRecord) error) error
+
+func recordsSource(records []importRecord) importSource {
+	return func(add func(importRecord) error) error {
+		for _, record := range records {
+			if err := add(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func importHistoryRecords(
+	targetDB *sql.DB,
+	records []importRecord,
+	opts importOptions,
+) (importSummary, error) {
+	return importFromSource(targetDB, recordsSource(records), opts)
+}
+
+func importFromSource(
+	targetDB *sql.DB,
+	source importSource,
+	opts importOptions,
 ) (importSummary, error) {
 	tx, err := targetDB.BeginTx(context.Background(), nil)
 	if err != nil {
@@ -314,33 +550,52 @@ func importHistoryEntries(
 	}()
 
 	summary := newImportSummary()
-	for _, entry := range entries {
+
+	err = source(func(record importRecord) error {
+		record = opts.pathMappings.apply(record)
+		entry := record.entry
 		summary.total++
 
 		if !opts.includeFailed && entry.ExitCode != 0 {
 			summary.skippedFailed++
-			continue
+			return nil
+		}
+
+		if !opts.selects(entry) {
+			summary.skippedFiltered++
+			return nil
 		}
 
 		if !opts.includeMissingPaths {
-			pathsExist, pathsErr := commandReferencesExistingPaths(entry.Command, entry.Directory)
+			pathsExist, pathsErr := record.pathsExist()
 			if pathsErr != nil || !pathsExist {
 				summary.skippedMissingPath++
-				continue
+				return nil
 			}
 		}
 
 		inserted, insertErr := db.InsertIfNotExistsTx(tx, entry)
 		if insertErr != nil {
-			return importSummary{}, fmt.Errorf("importing history entry: %w", insertErr)
+			return fmt.Errorf("importing history entry: %w", insertErr)
 		}
 
 		if inserted {
 			summary.imported++
-			continue
+			return nil
 		}
 
 		summary.skippedDuplicate++
+
+		return nil
+	})
+	if err != nil {
+		return importSummary{}, err
+	}
+
+	// A dry run leaves the deferred rollback to discard the inserts, so the
+	// duplicate counts still reflect what a real import would do.
+	if opts.dryRun {
+		return summary, nil
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -352,11 +607,42 @@ func importHistoryEntries(
 	return summary, nil
 }
 
+// selects reports whether entry passes the time range, host and config
+// filters.
+func (opts importOptions) selects(entry db.HistoryEntry) bool {
+	if opts.sinceMs > 0 && entry.TsMs < opts.sinceMs {
+		return false
+	}
+
+	if opts.untilMs > 0 && entry.TsMs >= opts.untilMs {
+		return false
+	}
+
+	if opts.host != "" && entry.Hostname != opts.host {
+		return false
+	}
+
+	if opts.filter != nil && !opts.filter.ShouldRecord(entry.Command, entry.ExitCode, entry.Directory) {
+		return false
+	}
+
+	return true
+}
+
+func (r importRecord) pathsExist() (bool, error) {
+	if r.recordedPaths {
+		return recordedPathsExist(r.paths, r.entry.Directory)
+	}
+
+	return commandReferencesExistingPaths(r.entry.Command, r.entry.Directory)
+}
+
 func newImportSummary() importSummary {
 	return importSummary{
 		total:              0,
 		imported:           0,
 		skippedFailed:      0,
+		skippedFiltered:    0,
 		skippedMissingPath: 0,
 		skippedDuplicate:   0,
 	}
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zigai/zgod/internal/db"
+	"github.com/zigai/zgod/internal/histfile"
+)
+
+const (
+	importFormatZgod  = "zgod"
+	importFormatBash  = "bash"
+	importFormatZsh   = "zsh"
+	importFormatFish  = "fish"
+	importFormatAtuin = "atuin"
+	importFormatMcfly = "mcfly"
+
+	importFormatPSReadLine = "psreadline"
+	importFormatJSONL      = "jsonl"
+)
+
+var (
+	importFormats = []string{
+		importFormatZgod,
+		importFormatBash,
+		importFormatZsh,
+		importFormatFish,
+		importFormatAtuin,
+		importFormatMcfly,
+		importFormatPSReadLine,
+		importFormatJSONL,
+	}
+	importFormatList = strings.Join(importFormats, ", ")
+
+	// importFormatShells names the shell that wrote each history file format.
+	importFormatShells = map[string]string{
+		importFormatBash:       "bash",
+		importFormatZsh:        "zsh",
+		importFormatFish:       "fish",
+		importFormatPSReadLine: "powershell",
+	}
+)
+
+func readImportSource(format string, sourcePath string) ([]importRecord, error) {
+	switch format {
+	case importFormatAtuin, importFormatMcfly:
+		entries, err := readForeignDatabase(format, sourcePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return recordsFromEntries(entries), nil
+	default:
+		return readHistoryFile(format, sourcePath)
+	}
+}
+
+func readForeignDatabase(format string, sourcePath string) ([]db.HistoryEntry, error) {
+	sourceDB, err := db.OpenReadOnly(sourcePath)
+	if err != nil {
+		return nil, wrapImportSourceAccessError("opening", sourcePath, err)
+	}
+
+	defer func() { _ = sourceDB.Close() }()
+
+	entries, err := listForeignEntries(format, sourceDB)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s database %q: %w", format, sourcePath, err)
+	}
+
+	// McFly does not record hosts; its history comes from this machine.
+	if format == importFormatMcfly {
+		hostname := getHostname()
+		for i := range entries {
+			entries[i].Hostname = hostname
+		}
+	}
+
+	return entries, nil
+}
+
+func listForeignEntries(format string, sourceDB *sql.DB) ([]db.HistoryEntry, error) {
+	switch format {
+	case importFormatAtuin:
+		return db.ReadAtuinHistory(sourceDB)
+	case importFormatMcfly:
+		return db.ReadMcflyHistory(sourceDB)
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnsupportedImportFormat, format)
+	}
+}
+
+func readHistoryFile(format string, sourcePath string) ([]importRecord, error) {
+	// #nosec G304 -- sourcePath is the user-provided import source
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, wrapImportSourceAccessError("opening", sourcePath, err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, wrapImportSourceAccessError("stating", sourcePath, err)
+	}
+
+	records, err := parseHistoryFile(format, f, info.ModTime().UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s history file %q: %w", format, sourcePath, err)
+	}
+
+	return records, nil
+}
+
+// readImportStream reads history piped to `zgod import -`. JSONL is decoded
+// line by line as the import consumes it; shell history is read up front
+// because its timestamps are synthesized from the end of the file.
+func readImportStream(format string, r io.Reader) (importSource, error) {
+	if !isHistoryFileFormat(format) {
+		return nil, fmt.Errorf("%w: %s imports need a database path", errImportStdinFormat, format)
+	}
+
+	if format == importFormatJSONL {
+		return jsonlStreamSource(r), nil
+	}
+
+	records, err := parseHistoryFile(format, r, time.Now().UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s history from stdin: %w", format, err)
+	}
+
+	return recordsSource(records), nil
+}
+
+func jsonlStreamSource(r io.Reader) importSource {
+	return func(add func(importRecord) error) error {
+		var addErr error
+
+		err := histfile.EachJSONL(r, func(entry db.HistoryEntry) error {
+			addErr = add(recordFromEntry(entry))
+			return addErr
+		})
+		if addErr != nil {
+			return addErr
+		}
+
+		if err != nil {
+			return fmt.Errorf("parsing %s history from stdin: %w", importFormatJSONL, err)
+		}
+
+		return nil
+	}
+}
+
+func isHistoryFileFormat(format string) bool {
+	if format == importFormatJSONL {
+		return true
+	}
+
+	_, ok := importFormatShells[format]
+
+	return ok
+}
+
+// parseHistoryFile reads a shell history file. modTimeMs is the file's
+// modification time, used to anchor formats that carry no timestamps.
+func parseHistoryFile(format string, r io.Reader, modTimeMs int64) ([]importRecord, error) {
+	if format == importFormatJSONL {
+		entries, err := histfile.ReadJSONL(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading JSONL history: %w", err)
+		}
+
+		return recordsFromEntries(entries), nil
+	}
+
+	records, err := parseShellHistory(format, r, modTimeMs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shell history files come from this machine and carry no host or shell.
+	hostname := getHostname()
+	for i := range records {
+		records[i].entry.Hostname = hostname
+		records[i].entry.Shell = importFormatShells[format]
+	}
+
+	return records, nil
+}
+
+func parseShellHistory(format string, r io.Reader, modTimeMs int64) ([]importRecord, error) {
+	switch format {
+	case importFormatBash:
+		entries, err := histfile.ReadBash(r, modTimeMs)
+		if err != nil {
+			return nil, fmt.Errorf("reading bash history: %w", err)
+		}
+
+		return recordsFromEntries(entries), nil
+	case importFormatZsh:
+		entries, err := histfile.ReadZsh(r, modTimeMs)
+		if err != nil {
+			return nil, fmt.Errorf("reading zsh history: %w", err)
+		}
+
+		return recordsFromEntries(entries), nil
+	case importFormatFish:
+		entries, err := histfile.ReadFish(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading fish history: %w", err)
+		}
+
+		records := make([]importRecord, len(entries))
+		for i, e := range entries {
+			records[i] = importRecord{entry: e.Entry, paths: e.Paths, recordedPaths: true}
+		}
+
+		return records, nil
+	case importFormatPSReadLine:
+		entries, err := histfile.ReadPSReadLine(r, modTimeMs)
+		if err != nil {
+			return nil, fmt.Errorf("reading PSReadLine history: %w", err)
+		}
+
+		return recordsFromEntries(entries), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnsupportedImportFormat, format)
+	}
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var errInvalidPathMapping = errors.New("invalid path mapping")
+
+// pathMapping rewrites paths under from to the same location under to.
+type pathMapping struct {
+	from string
+	to   string
+}
+
+// pathMappings is ordered longest prefix first, so nested rules such as
+// /src=/code and /src/old=/archive pick the most specific one.
+type pathMappings []pathMapping
+
+func parsePathMappings(rules []string) (pathMappings, error) {
+	mappings := make(pathMappings, 0, len(rules))
+
+	for _, rule := range rules {
+		from, to, found := strings.Cut(rule, "=")
+		from = trimTrailingSeparators(strings.TrimSpace(from))
+		to = trimTrailingSeparators(strings.TrimSpace(to))
+
+		if !found || from == "" || to == "" {
+			return nil, fmt.Errorf("%w %q: expected OLD=NEW", errInvalidPathMapping, rule)
+		}
+
+		mappings = append(mappings, pathMapping{from: from, to: to})
+	}
+
+	sort.SliceStable(mappings, func(a, b int) bool {
+		return len(mappings[a].from) > len(mappings[b].from)
+	})
+
+	return mappings, nil
+}
+
+func trimTrailingSeparators(path string) string {
+	trimmed := strings.TrimRight(path, `/\`)
+	if trimmed == "" {
+		return path
+	}
+
+	return trimmed
+}
+
+// apply rewrites the directory, command and recorded paths of an import
+// record.
+func (m pathMappings) apply(r importRecord) importRecord {
+	if len(m) == 0 {
+		return r
+	}
+
+	r.entry.Directory = m.rewriteDirectory(r.entry.Directory)
+	r.entry.Command = m.rewriteCommand(r.entry.Command)
+	r.paths = m.rewritePaths(r.paths)
+
+	return r
+}
+
+// rewriteDirectory maps a recorded working directory.
+func (m pathMappings) rewriteDirectory(directory string) string {
+	for _, mapping := range m {
+		if directory == mapping.from {
+			return mapping.to
+		}
+
+		if rest, ok := strings.CutPrefix(directory, mapping.from); ok && isPathSeparator(rest[0]) {
+			return mapping.to + rest
+		}
+	}
+
+	return directory
+}
+
+// rewriteCommand maps path tokens inside a command line. A prefix only
+// matches where a path can start, such as after whitespace, a quote, "=" or a
+// redirection, and where it ends at a path component boundary, so /src does
+// not rewrite /srcfoo or /opt/src.
+func (m pathMappings) rewriteCommand(command string) string {
+	if len(m) == 0 {
+		return command
+	}
+
+	var b strings.Builder
+
+	b.Grow(len(command))
+
+	for i := 0; i < len(command); {
+		if mapping, ok := m.matchAt(command, i); ok {
+			b.WriteString(mapping.to)
+
+			i += len(mapping.from)
+
+			continue
+		}
+
+		b.WriteByte(command[i])
+		i++
+	}
+
+	return b.String()
+}
+
+func (m pathMappings) rewritePaths(recordedPaths []string) []string {
+	if len(m) == 0 || len(recordedPaths) == 0 {
+		return recordedPaths
+	}
+
+	rewritten := make([]string, len(recordedPaths))
+	for i, path := range recordedPaths {
+		rewritten[i] = m.rewriteCommand(path)
+	}
+
+	return rewritten
+}
+
+func (m pathMappings) matchAt(command string, start int) (pathMapping, bool) {
+	if start > 0 && !isPathStartBoundary(command[start-1]) {
+		return pathMapping{}, false
+	}
+
+	for _, mapping := range m {
+		end := start + len(mapping.from)
+		if !strings.HasPrefix(command[start:], mapping.from) {
+			continue
+		}
+
+		if end == len(command) || isPathEndBoundary(command[end]) {
+			return mapping, true
+		}
+	}
+
+	return pathMapping{}, false
+}
+
+func isPathSeparator(c byte) bool {
+	return c == '/' || c == '\\'
+}
+
+func isPathStartBoundary(c byte) bool {
+	return strings.IndexByte(" \t\n'\"=<>:(", c) >= 0
+}
+
+func isPathEndBoundary(c byte) bool {
+	return isPathSeparator(c) || strings.IndexByte(" \t\n'\";|&)<>:,", c) >= 0
+}
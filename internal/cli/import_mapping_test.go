@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+func TestPathMappingsRewriteDirectoriesAndCommandTokens(t *testing.T) {
+	mappings, err := parsePathMappings([]string{"/Users/alice/src=/home/alice/code", "/Users/alice/src/old/=/archive"})
+	if err != nil {
+		t.Fatalf("parsePathMappings() error: %v", err)
+	}
+
+	directories := map[string]string{
+		"/Users/alice/src":          "/home/alice/code",
+		"/Users/alice/src/zgod":     "/home/alice/code/zgod",
+		"/Users/alice/src/old/tool": "/archive/tool",
+		"/Users/alice/srcfoo":       "/Users/alice/srcfoo",
+	}
+	for input, want := range directories {
+		if got := mappings.rewriteDirectory(input); got != want {
+			t.Fatalf("rewriteDirectory(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	commands := map[string]string{
+		"cd /Users/alice/src/zgod && make":                   "cd /home/alice/code/zgod && make",
+		`vim "/Users/alice/src/a b.txt"`:                     `vim "/home/alice/code/a b.txt"`,
+		"cat </Users/alice/src/old/x --out=/Users/alice/src": "cat </archive/x --out=/home/alice/code",
+		"ls /opt/Users/alice/src /Users/alice/srcfoo":        "ls /opt/Users/alice/src /Users/alice/srcfoo",
+	}
+	for input, want := range commands {
+		if got := mappings.rewriteCommand(input); got != want {
+			t.Fatalf("rewriteCommand(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParsePathMappingsRejectsMalformedRules(t *testing.T) {
+	for _, rule := range []string{"/only-old", "=/new", "/old="} {
+		if _, err := parsePathMappings([]string{rule}); !errors.Is(err, errInvalidPathMapping) {
+			t.Fatalf("parsePathMappings(%q) error = %v, want %v", rule, err, errInvalidPathMapping)
+		}
+	}
+}
+
+func TestImportHistoryRecordsAppliesSelectionMappingAndDryRun(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	mappings, err := parsePathMappings([]string{"/old/src=/new/src"})
+	if err != nil {
+		t.Fatalf("parsePathMappings() error: %v", err)
+	}
+
+	entries := []db.HistoryEntry{
+		{TsMs: 1000, Command: "too old", Hostname: "laptop"},
+		{TsMs: 2000, Command: "make -C /old/src/app", Directory: "/old/src", Hostname: "laptop"},
+		{TsMs: 3000, Command: "other host", Hostname: "server"},
+	}
+	opts := importOptions{
+		includeFailed:       false,
+		includeMissingPaths: true,
+		pathMappings:        mappings,
+		sinceMs:             1500,
+		untilMs:             0,
+		host:                "laptop",
+		filter:              nil,
+		dryRun:              true,
+	}
+
+	summary, err := importHistoryEntries(database, entries, opts)
+	if err != nil {
+		t.Fatalf("importHistoryEntries() dry run error: %v", err)
+	}
+
+	if summary.imported != 1 || summary.skippedFiltered != 2 {
+		t.Fatalf("dry run summary = %+v, want imported=1 skipped_filtered=2", summary)
+	}
+
+	stored, err := db.NewHistoryRepo(database).ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	if len(stored) != 0 {
+		t.Fatalf("dry run stored %d entries, want 0", len(stored))
+	}
+
+	opts.dryRun = false
+	if _, err = importHistoryEntries(database, entries, opts); err != nil {
+		t.Fatalf("importHistoryEntries() error: %v", err)
+	}
+
+	stored, err = db.NewHistoryRepo(database).ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	if len(stored) != 1 || stored[0].Directory != "/new/src" || stored[0].Command != "make -C /new/src/app" {
+		t.Fatalf("stored = %+v, want one remapped entry", stored)
+	}
+}
@@ -50,6 +50,12 @@ func commandReferencesExistingPaths(command string, workingDirectory string) (bo
 			return false, fmt.Errorf("resolving path candidate %q: %w", candidate.value, resolveErr)
 		}
 
+		// Relative paths cannot be verified for entries recorded without a directory,
+		// such as those imported from shell history files.
+		if workingDirectory == "" && !isAbsoluteCommandPath(resolvedPath) {
+			continue
+		}
+
 		exists, existsErr := commandPathMatchesRequirement(resolvedPath, candidate.requirement)
 		if existsErr != nil {
 			return false, fmt.Errorf("checking path candidate %q: %w", candidate.value, existsErr)
@@ -63,6 +69,30 @@ func commandReferencesExistingPaths(command string, workingDirectory string) (bo
 	return true, nil
 }
 
+func recordedPathsExist(recordedPaths []string, workingDirectory string) (bool, error) {
+	for _, recorded := range recordedPaths {
+		resolvedPath, err := resolveCommandPath(recorded, workingDirectory)
+		if err != nil {
+			return false, fmt.Errorf("resolving recorded path %q: %w", recorded, err)
+		}
+
+		if workingDirectory == "" && !isAbsoluteCommandPath(resolvedPath) {
+			continue
+		}
+
+		exists, err := commandPathExists(resolvedPath)
+		if err != nil {
+			return false, fmt.Errorf("checking recorded path %q: %w", recorded, err)
+		}
+
+		if !exists {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
 func extractPathCandidates(tokens []string, workingDirectory string) []pathCandidate {
 	commandName, commandIndex := primaryCommand(tokens)
 	extractor := pathExtractor{
@@ -607,6 +637,10 @@ func resolveCommandPath(pathCandidate string, workingDirectory string) (string,
 	return filepath.Clean(filepath.Join(workingDirectory, expandedPath)), nil
 }
 
+func isAbsoluteCommandPath(path string) bool {
+	return filepath.IsAbs(path) || hasWindowsDrivePrefix(path)
+}
+
 func commandPathMatchesRequirement(path string, requirement pathRequirement) (bool, error) {
 	if requirement == pathParentMustExist {
 		parent := filepath.Dir(path)
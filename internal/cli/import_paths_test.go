@@ -266,3 +266,25 @@ func TestCommandReferencesExistingPathsGlob(t *testing.T) {
 		t.Fatal("expected glob to pass when at least one match exists")
 	}
 }
+
+func TestCommandReferencesExistingPathsSkipsRelativePathsWithoutDirectory(t *testing.T) {
+	ok, err := commandReferencesExistingPaths("cat ./missing.txt", "")
+	if err != nil {
+		t.Fatalf("commandReferencesExistingPaths() error: %v", err)
+	}
+
+	if !ok {
+		t.Fatal("expected relative path to be ignored when the working directory is unknown")
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+
+	ok, err = commandReferencesExistingPaths("cat "+filepath.ToSlash(missing), "")
+	if err != nil {
+		t.Fatalf("commandReferencesExistingPaths() error: %v", err)
+	}
+
+	if ok {
+		t.Fatal("expected missing absolute path to fail without a working directory")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/zigai/zgod/internal/db"
 )
@@ -187,6 +188,178 @@ func TestOpenImportDatabasesReadableSourceDoesNotRequireAuth(t *testing.T) {
 	}
 }
 
+func TestReadHistoryFileImportsZshHistory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	historyPath := filepath.Join(t.TempDir(), ".zsh_history")
+	content := ": 1700000000:1;git status\n: 1700000005:0;cat ./notes.txt\n: 1700000009:2;false\n"
+
+	if writeErr := os.WriteFile(historyPath, []byte(content), 0o600); writeErr != nil {
+		t.Fatalf("WriteFile() error: %v", writeErr)
+	}
+
+	records, err := readHistoryFile(importFormatZsh, historyPath)
+	if err != nil {
+		t.Fatalf("readHistoryFile() error: %v", err)
+	}
+
+	summary, err := importHistoryRecords(database, records, importOptions{})
+	if err != nil {
+		t.Fatalf("importHistoryRecords() error: %v", err)
+	}
+
+	if summary.total != 3 || summary.imported != 3 {
+		t.Fatalf("summary = %+v, want total=3 imported=3", summary)
+	}
+
+	summary, err = importHistoryRecords(database, records, importOptions{})
+	if err != nil {
+		t.Fatalf("importHistoryRecords() second run error: %v", err)
+	}
+
+	if summary.skippedDuplicate != 3 {
+		t.Fatalf("summary.skippedDuplicate = %d, want 3", summary.skippedDuplicate)
+	}
+}
+
+func TestReadHistoryFileAnchorsPSReadLineTimestampsToModTime(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "ConsoleHost_history.txt")
+	content := "Get-Location\r\nWrite-Output `\r\n  done\r\n"
+
+	if err := os.WriteFile(historyPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	modTime := time.UnixMilli(1_700_000_000_000)
+	if err := os.Chtimes(historyPath, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes() error: %v", err)
+	}
+
+	records, err := readHistoryFile(importFormatPSReadLine, historyPath)
+	if err != nil {
+		t.Fatalf("readHistoryFile() error: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("readHistoryFile() returned %d records, want 2", len(records))
+	}
+
+	last := records[1].entry
+	if last.TsMs != modTime.UnixMilli() || last.Command != "Write-Output \n  done" {
+		t.Fatalf("records[1].entry = %+v", last)
+	}
+
+	if records[0].entry.TsMs >= last.TsMs {
+		t.Fatalf("timestamps not increasing: %d >= %d", records[0].entry.TsMs, last.TsMs)
+	}
+
+	for i, record := range records {
+		if record.entry.Shell != "powershell" {
+			t.Fatalf("records[%d].entry.Shell = %q, want %q", i, record.entry.Shell, "powershell")
+		}
+	}
+}
+
+func TestReadHistoryFileAnchorsUntimestampedBashToModTime(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), ".bash_history")
+
+	if err := os.WriteFile(historyPath, []byte("ls\nmake\nls\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	modTime := time.UnixMilli(1_700_000_000_000)
+	if err := os.Chtimes(historyPath, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes() error: %v", err)
+	}
+
+	records, err := readHistoryFile(importFormatBash, historyPath)
+	if err != nil {
+		t.Fatalf("readHistoryFile() error: %v", err)
+	}
+
+	if len(records) != 3 || records[2].entry.TsMs != modTime.UnixMilli() {
+		t.Fatalf("readHistoryFile() = %+v, want 3 records ending at the mtime", records)
+	}
+
+	if records[0].entry.TsMs == records[2].entry.TsMs {
+		t.Fatalf("repeated commands share timestamp %d", records[0].entry.TsMs)
+	}
+}
+
+func TestReadHistoryFileAnchorsPlainZshToModTime(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), ".zsh_history")
+
+	if err := os.WriteFile(historyPath, []byte("ls\nmake\nls\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	modTime := time.UnixMilli(1_700_000_000_000)
+	if err := os.Chtimes(historyPath, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes() error: %v", err)
+	}
+
+	records, err := readHistoryFile(importFormatZsh, historyPath)
+	if err != nil {
+		t.Fatalf("readHistoryFile() error: %v", err)
+	}
+
+	if len(records) != 3 || records[2].entry.TsMs != modTime.UnixMilli() {
+		t.Fatalf("readHistoryFile() = %+v, want 3 records ending at the mtime", records)
+	}
+
+	if records[0].entry.TsMs == records[2].entry.TsMs {
+		t.Fatalf("repeated commands share timestamp %d", records[0].entry.TsMs)
+	}
+}
+
+func TestImportHistoryRecordsUsesRecordedFishPaths(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	existing := filepath.Join(t.TempDir(), "notes.txt")
+	if writeErr := os.WriteFile(existing, []byte("ok"), 0o600); writeErr != nil {
+		t.Fatalf("WriteFile() error: %v", writeErr)
+	}
+
+	missing := filepath.Join(t.TempDir(), "gone.txt")
+
+	historyPath := filepath.Join(t.TempDir(), "fish_history")
+	content := "- cmd: vim notes\n  when: 1700000000\n  paths:\n    - " + filepath.ToSlash(existing) + "\n" +
+		"- cmd: cat gone\n  when: 1700000001\n  paths:\n    - " + filepath.ToSlash(missing) + "\n" +
+		"- cmd: cat /definitely/missing/file\n  when: 1700000002\n"
+
+	if writeErr := os.WriteFile(historyPath, []byte(content), 0o600); writeErr != nil {
+		t.Fatalf("WriteFile() error: %v", writeErr)
+	}
+
+	records, err := readHistoryFile(importFormatFish, historyPath)
+	if err != nil {
+		t.Fatalf("readHistoryFile() error: %v", err)
+	}
+
+	summary, err := importHistoryRecords(database, records, importOptions{})
+	if err != nil {
+		t.Fatalf("importHistoryRecords() error: %v", err)
+	}
+
+	if summary.imported != 2 || summary.skippedMissingPath != 1 {
+		t.Fatalf("summary = %+v, want imported=2 skipped_missing_paths=1", summary)
+	}
+}
+
 func setImportHomes(t *testing.T) {
 	t.Helper()
 
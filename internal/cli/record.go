@@ -86,8 +86,7 @@ func runRecord(cmd *cobra.Command, args []string) error {
 	hostname := getHostname()
 
 	repo := db.NewHistoryRepo(database)
-
-	_, err = repo.Insert(db.HistoryEntry{
+	entry := db.HistoryEntry{
 		ID:        0,
 		TsMs:      ts,
 		Duration:  duration,
@@ -96,7 +95,17 @@ func runRecord(cmd *cobra.Command, args []string) error {
 		Directory: directory,
 		SessionID: sessionID,
 		Hostname:  hostname,
-	})
+		RunCount:  1,
+		Shell:     "",
+		FirstTsMs: 0,
+	}
+
+	if cfg.DB.CollapseDuplicates {
+		_, err = repo.InsertCollapsed(entry)
+	} else {
+		_, err = repo.Insert(entry)
+	}
+
 	if err != nil {
 		if db.IsBusyError(err) {
 			return nil
@@ -35,11 +35,16 @@ func setupCommands() {
 	setupCommandsOnce.Do(func() {
 		rootCmd.Flags().BoolP("version", "v", false, "Print version")
 		registerConfigCommand()
+		registerDBCommand()
+		registerExportCommand()
 		registerImportCommand()
 		registerInitCommand()
 		registerInstallCommand()
 		registerRecordCommand()
 		registerSearchCommand()
+		registerServeCommand()
+		registerStatsCommand()
+		registerSyncCommand()
 	})
 }
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -12,11 +13,15 @@ import (
 
 	"github.com/zigai/zgod/internal/config"
 	"github.com/zigai/zgod/internal/db"
+	"github.com/zigai/zgod/internal/history"
 	"github.com/zigai/zgod/internal/paths"
 	"github.com/zigai/zgod/internal/tui"
 )
 
-var errUnexpectedModelType = errors.New("unexpected model type")
+var (
+	errUnexpectedModelType = errors.New("unexpected model type")
+	errInvalidScope        = errors.New("invalid scope")
+)
 
 var searchCmd = &cobra.Command{
 	Use:   "search",
@@ -39,9 +44,12 @@ type searchContext struct {
 }
 
 func registerSearchCommand() {
-	searchCmd.Flags().Bool("cwd", false, "filter by current directory")
+	searchCmd.Flags().String("cwd", "", "directory scope: global, repo, subtree or exact (default: display.default_scope)")
+	searchCmd.Flags().Lookup("cwd").NoOptDefVal = history.ScopeExact.String()
 	searchCmd.Flags().Int("height", searchDefaultHeight, "visible result lines")
 	searchCmd.Flags().String("query", "", "initial search query")
+	searchCmd.Flags().String("session", "", "shell session ID, used to rank the session's commands and likely next steps")
+	searchCmd.Flags().String("last-command", "", "previous command (default: the session's last recorded command)")
 	rootCmd.AddCommand(searchCmd)
 }
 
@@ -99,7 +107,12 @@ func prepareSearchContext(cmd *cobra.Command) (searchContext, error) {
 		return searchContext{}, fmt.Errorf("opening database: %w", err)
 	}
 
-	cwdFlag, _ := cmd.Flags().GetBool("cwd")
+	scope, err := searchScope(cmd, cfg)
+	if err != nil {
+		_ = database.Close()
+		return searchContext{}, err
+	}
+
 	height, _ := cmd.Flags().GetInt("height")
 	query, _ := cmd.Flags().GetString("query")
 
@@ -136,7 +149,10 @@ func prepareSearchContext(cmd *cobra.Command) (searchContext, error) {
 	}
 
 	repo := db.NewHistoryRepo(database)
-	model := tui.NewModel(cfg, repo, cwd, homeDir, height, cwdFlag, query)
+	model := tui.NewModel(cfg, repo, cwd, homeDir, height, scope, query)
+	session, _ := cmd.Flags().GetString("session")
+	model.SetSearchContext(session, getHostname(), previousCommand(cmd, repo, session))
+
 	cleanup := func() {
 		ttyCleanup()
 
@@ -152,6 +168,61 @@ func prepareSearchContext(cmd *cobra.Command) (searchContext, error) {
 	}, nil
 }
 
+// searchScope returns display.default_scope with the directory scope
+// replaced by --cwd when given.
+func searchScope(cmd *cobra.Command, cfg config.Config) (history.SearchScope, error) {
+	scope, ok := history.ParseSearchScope(cfg.Display.DefaultScope)
+	if !ok {
+		return scope, fmt.Errorf("%w %q", errInvalidScope, cfg.Display.DefaultScope)
+	}
+
+	if !cmd.Flags().Changed("cwd") {
+		return scope, nil
+	}
+
+	value, _ := cmd.Flags().GetString("cwd")
+
+	scope.Directory, ok = parseCWDFlag(value)
+	if !ok {
+		return scope, fmt.Errorf("%w %q: must be global, repo, subtree, or exact", errInvalidScope, value)
+	}
+
+	return scope, nil
+}
+
+// parseCWDFlag parses --cwd, which still accepts the boolean values it took
+// before it named a scope: true means exact and false means global.
+func parseCWDFlag(value string) (history.Scope, bool) {
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		if enabled {
+			return history.ScopeExact, true
+		}
+
+		return history.ScopeGlobal, true
+	}
+
+	return history.ParseScope(value)
+}
+
+// previousCommand returns --last-command, falling back to the last command
+// recorded in --session.
+func previousCommand(cmd *cobra.Command, repo *db.HistoryRepo, session string) string {
+	if last, _ := cmd.Flags().GetString("last-command"); last != "" {
+		return last
+	}
+
+	if session == "" {
+		return ""
+	}
+
+	last, _, err := repo.LastSessionCommand(session)
+	if err != nil {
+		return ""
+	}
+
+	return last
+}
+
 func resolveSearchResult(cfg config.Config, finalModel tea.Model) (int, error) {
 	m, ok := finalModel.(*tui.Model)
 	if !ok {
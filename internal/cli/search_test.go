@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zigai/zgod/internal/config"
+	"github.com/zigai/zgod/internal/history"
+)
+
+func TestSearchScopeCWDFlag(t *testing.T) {
+	cfg := config.Default()
+	cfg.Display.DefaultScope = "repo"
+
+	for _, tc := range []struct {
+		args []string
+		want history.Scope
+	}{
+		{args: nil, want: history.ScopeRepo},
+		{args: []string{"--cwd"}, want: history.ScopeExact},
+		{args: []string{"--cwd=subtree"}, want: history.ScopeSubtree},
+		{args: []string{"--cwd=global"}, want: history.ScopeGlobal},
+		{args: []string{"--cwd=true"}, want: history.ScopeExact},
+		{args: []string{"--cwd=false"}, want: history.ScopeGlobal},
+	} {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("cwd", "", "")
+		cmd.Flags().Lookup("cwd").NoOptDefVal = history.ScopeExact.String()
+
+		if err := cmd.ParseFlags(tc.args); err != nil {
+			t.Fatalf("ParseFlags(%v) error: %v", tc.args, err)
+		}
+
+		scope, err := searchScope(cmd, cfg)
+		if err != nil {
+			t.Fatalf("searchScope(%v) error: %v", tc.args, err)
+		}
+
+		if scope.Directory != tc.want {
+			t.Errorf("searchScope(%v) = %v, want %v", tc.args, scope.Directory, tc.want)
+		}
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("cwd", "", "")
+
+	if err := cmd.ParseFlags([]string{"--cwd=home"}); err != nil {
+		t.Fatalf("ParseFlags(--cwd=home) error: %v", err)
+	}
+
+	if _, err := searchScope(cmd, cfg); !errors.Is(err, errInvalidScope) {
+		t.Fatalf("searchScope(--cwd=home) error = %v, want %v", err, errInvalidScope)
+	}
+}
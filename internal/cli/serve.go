@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zigai/zgod/internal/config"
+	"github.com/zigai/zgod/internal/db"
+	"github.com/zigai/zgod/internal/histsync"
+)
+
+var errNothingToServe = errors.New("nothing to serve: pass --sync")
+
+const (
+	serveDefaultAddr       = "127.0.0.1:8765"
+	serveReadHeaderTimeout = 10 * time.Second
+	serveShutdownTimeout   = 5 * time.Second
+	serveStoreFileName     = "sync-server.db"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run a history sync server",
+	Long: "Run an HTTP server that relays history between machines running `zgod sync --server`. " +
+		"Each machine authenticates with its own token, created with `zgod serve token <host-id>`.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE:         runServe,
+}
+
+var serveTokenCmd = &cobra.Command{
+	Use:          "token <host-id>",
+	Short:        "Create or rotate the sync token for a host",
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runServeToken,
+}
+
+func registerServeCommand() {
+	serveCmd.PersistentFlags().String("store", "", "Sync server database (default: sync-server.db next to the history database)")
+	serveCmd.Flags().Bool("sync", false, "Serve the sync API")
+	serveCmd.Flags().String("addr", serveDefaultAddr, "Address to listen on")
+	serveCmd.AddCommand(serveTokenCmd)
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if enabled, _ := cmd.Flags().GetBool("sync"); !enabled {
+		return errNothingToServe
+	}
+
+	addr, _ := cmd.Flags().GetString("addr")
+
+	store, err := openServeStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = store.Close() }()
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           histsync.NewServer(store).Handler(),
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+
+	go func() { errs <- server.ListenAndServe() }()
+
+	cmd.Printf("Serving sync API on %s\n", addr)
+
+	select {
+	case err = <-errs:
+		return fmt.Errorf("serving sync API: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancel()
+
+	if err = server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down sync server: %w", err)
+	}
+
+	return nil
+}
+
+func runServeToken(cmd *cobra.Command, args []string) error {
+	store, err := openServeStore(cmd)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = store.Close() }()
+
+	token, err := histsync.IssueToken(store, args[0])
+	if err != nil {
+		return fmt.Errorf("issuing token for %q: %w", args[0], err)
+	}
+
+	cmd.Println(token)
+
+	return nil
+}
+
+func openServeStore(cmd *cobra.Command) (*db.SyncStore, error) {
+	storePath, _ := cmd.Flags().GetString("store")
+	if storePath == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, fmt.Errorf("loading config: %w", err)
+		}
+
+		dbPath, err := cfg.DatabasePath()
+		if err != nil {
+			return nil, fmt.Errorf("resolving database path: %w", err)
+		}
+
+		storePath = filepath.Join(filepath.Dir(dbPath), serveStoreFileName)
+	}
+
+	store, err := db.OpenSyncStore(storePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening sync store: %w", err)
+	}
+
+	return store, nil
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+// statsHistogramRows is the number of positions shown individually before
+// the rest are grouped into one row.
+const statsHistogramRows = 10
+
+var statsCmd = &cobra.Command{
+	Use:          "stats",
+	Short:        "Show how deep in the result list selections are made",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE:         runStats,
+}
+
+func registerStatsCommand() {
+	rootCmd.AddCommand(statsCmd)
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	database, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = database.Close() }()
+
+	positions, err := db.NewHistoryRepo(database).SelectionPositions()
+	if err != nil {
+		return fmt.Errorf("loading selections: %w", err)
+	}
+
+	writeSelectionStats(cmd.OutOrStdout(), summarizePositions(positions))
+
+	return nil
+}
+
+// selectionSummary describes selection positions, which are 1-based here.
+type selectionSummary struct {
+	total  int
+	first  int
+	mean   float64
+	median int
+	// buckets holds counts for positions 1..statsHistogramRows, followed by
+	// one count for everything deeper.
+	buckets []int
+}
+
+func summarizePositions(positions map[int]int) selectionSummary {
+	summary := selectionSummary{
+		total:   0,
+		first:   positions[0],
+		mean:    0,
+		median:  0,
+		buckets: make([]int, statsHistogramRows+1),
+	}
+
+	keys := make([]int, 0, len(positions))
+	sum := 0
+
+	for position, count := range positions {
+		keys = append(keys, position)
+		summary.total += count
+		sum += (position + 1) * count
+		summary.buckets[min(position, statsHistogramRows)] += count
+	}
+
+	if summary.total == 0 {
+		return summary
+	}
+
+	summary.mean = float64(sum) / float64(summary.total)
+
+	sort.Ints(keys)
+
+	seen := 0
+	for _, position := range keys {
+		seen += positions[position]
+		if seen*2 >= summary.total {
+			summary.median = position + 1
+			break
+		}
+	}
+
+	return summary
+}
+
+func writeSelectionStats(w io.Writer, summary selectionSummary) {
+	if summary.total == 0 {
+		_, _ = fmt.Fprintln(w, "No selections recorded yet.")
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "Selections:      %d\n", summary.total)
+	_, _ = fmt.Fprintf(w, "First result:    %.1f%%\n", percent(summary.first, summary.total))
+	_, _ = fmt.Fprintf(w, "Mean position:   %.2f\n", summary.mean)
+	_, _ = fmt.Fprintf(w, "Median position: %d\n", summary.median)
+	_, _ = fmt.Fprintln(w)
+
+	for i, count := range summary.buckets {
+		label := fmt.Sprintf("%d", i+1)
+		if i == statsHistogramRows {
+			label = fmt.Sprintf("%d+", statsHistogramRows+1)
+		}
+
+		_, _ = fmt.Fprintf(w, "%4s  %6d  %5.1f%%\n", label, count, percent(count, summary.total))
+	}
+}
+
+func percent(part int, total int) float64 {
+	return 100 * float64(part) / float64(total)
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSummarizePositions(t *testing.T) {
+	summary := summarizePositions(map[int]int{0: 6, 1: 2, 4: 1, 15: 1})
+
+	if summary.total != 10 || summary.first != 6 || summary.median != 1 {
+		t.Fatalf("summary = %+v, want total=10 first=6 median=1", summary)
+	}
+
+	if summary.mean != 3.1 {
+		t.Fatalf("summary.mean = %v, want 3.1", summary.mean)
+	}
+
+	want := []int{6, 2, 0, 0, 1, 0, 0, 0, 0, 0, 1}
+	if !slices.Equal(summary.buckets, want) {
+		t.Fatalf("summary.buckets = %v, want %v", summary.buckets, want)
+	}
+
+	var buf bytes.Buffer
+
+	writeSelectionStats(&buf, summary)
+
+	if !strings.Contains(buf.String(), "First result:    60.0%") || !strings.Contains(buf.String(), " 11+       1") {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestWriteSelectionStatsWithoutSelections(t *testing.T) {
+	var buf bytes.Buffer
+
+	writeSelectionStats(&buf, summarizePositions(map[int]int{}))
+
+	if !strings.HasPrefix(buf.String(), "No selections") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zigai/zgod/internal/config"
+	"github.com/zigai/zgod/internal/histsync"
+)
+
+var (
+	errSyncTargetRequired  = errors.New("a sync target is required: pass --dir or --server, or set them in [sync]")
+	errSyncTargetAmbiguous = errors.New("--dir and --server cannot be used together")
+	errSyncTokenRequired   = errors.New("a sync token is required: pass --token or set token in [sync]")
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync history with other machines",
+	Long: "Exchange history with other machines through a shared folder or a `zgod serve --sync` server. " +
+		"Each machine publishes its own inserts and deletions and merges those of the others. " +
+		"Syncing is idempotent and safe to run repeatedly.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE:         runSync,
+}
+
+var syncKeygenCmd = &cobra.Command{
+	Use:          "keygen",
+	Short:        "Print a new key for end-to-end encrypted server sync",
+	Long:         "Print a new random key. Set it as encryption_key in the [sync] config of every machine that syncs together.",
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE:         runSyncKeygen,
+}
+
+func registerSyncCommand() {
+	syncCmd.Flags().String("dir", "", "Shared folder holding the per-host change logs")
+	syncCmd.Flags().String("host-id", "", "Name of this machine's change log (default: hostname)")
+	syncCmd.Flags().String("server", "", "URL of a `zgod serve --sync` server")
+	syncCmd.Flags().String("token", "", "This machine's token for the sync server")
+	syncCmd.AddCommand(syncKeygenCmd)
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	dir := flagOrDefault(cmd, "dir", cfg.Sync.Dir)
+	server := flagOrDefault(cmd, "server", cfg.Sync.Server)
+
+	switch {
+	case cmd.Flags().Changed("dir") && cmd.Flags().Changed("server"):
+		return errSyncTargetAmbiguous
+	case cmd.Flags().Changed("dir"):
+		server = ""
+	case cmd.Flags().Changed("server"):
+		dir = ""
+	}
+
+	if dir == "" && server == "" {
+		return errSyncTargetRequired
+	}
+
+	database, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = database.Close() }()
+
+	var summary histsync.Summary
+	if server != "" {
+		summary, err = syncWithServer(cmd, cfg, database, server)
+	} else {
+		summary, err = syncWithDir(cmd, database, dir)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	printSyncSummary(cmd, summary)
+
+	return nil
+}
+
+func syncWithDir(cmd *cobra.Command, database *sql.DB, dir string) (histsync.Summary, error) {
+	dir, err := resolveDirectoryFlag(dir)
+	if err != nil {
+		return histsync.Summary{}, err
+	}
+
+	hostID, _ := cmd.Flags().GetString("host-id")
+	if hostID == "" {
+		hostID = getHostname()
+	}
+
+	summary, err := histsync.SyncDir(database, dir, hostID)
+	if err != nil {
+		return histsync.Summary{}, fmt.Errorf("syncing with %q: %w", dir, err)
+	}
+
+	return summary, nil
+}
+
+func syncWithServer(
+	cmd *cobra.Command,
+	cfg config.Config,
+	database *sql.DB,
+	server string,
+) (histsync.Summary, error) {
+	token := flagOrDefault(cmd, "token", cfg.Sync.Token)
+	if token == "" {
+		return histsync.Summary{}, errSyncTokenRequired
+	}
+
+	key, err := histsync.ParseKey(cfg.Sync.EncryptionKey)
+	if err != nil {
+		return histsync.Summary{}, fmt.Errorf("reading [sync] encryption_key: %w", err)
+	}
+
+	summary, err := histsync.SyncServer(database, histsync.NewClient(server, token, key))
+	if err != nil {
+		return histsync.Summary{}, fmt.Errorf("syncing with %s: %w", server, err)
+	}
+
+	return summary, nil
+}
+
+func runSyncKeygen(cmd *cobra.Command, args []string) error {
+	key, err := histsync.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	cmd.Println(key)
+
+	return nil
+}
+
+// flagOrDefault returns the flag's value when it was set on the command line
+// and fallback otherwise.
+func flagOrDefault(cmd *cobra.Command, name string, fallback string) string {
+	if !cmd.Flags().Changed(name) {
+		return fallback
+	}
+
+	value, _ := cmd.Flags().GetString(name)
+
+	return value
+}
+
+func printSyncSummary(cmd *cobra.Command, summary histsync.Summary) {
+	cmd.Printf(
+		"Sync complete: pushed=%d merged=%d deleted=%d sources=%d\n",
+		summary.Pushed,
+		summary.Merged,
+		summary.Deleted,
+		summary.Sources,
+	)
+}
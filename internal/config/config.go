@@ -8,19 +8,33 @@ import (
 	"github.com/BurntSushi/toml"
 
 	"github.com/zigai/zgod/internal/db"
+	"github.com/zigai/zgod/internal/match"
 	"github.com/zigai/zgod/internal/paths"
 )
 
 type Config struct {
 	DB      DBConfig      `toml:"db"`
+	Sync    SyncConfig    `toml:"sync"`
 	Filters FilterConfig  `toml:"filters"`
 	Theme   ThemeConfig   `toml:"theme"`
 	Display DisplayConfig `toml:"display"`
+	Ranking RankingConfig `toml:"ranking"`
 	Keys    KeyConfig     `toml:"keys"`
 }
 
 type DBConfig struct {
-	Path string `toml:"path"`
+	Path               string `toml:"path"`
+	CollapseDuplicates bool   `toml:"collapse_duplicates"`
+}
+
+// SyncConfig holds the defaults for `zgod sync`. EncryptionKey, when set,
+// encrypts history sent to a sync server so the server only stores
+// ciphertext; every machine syncing together needs the same key.
+type SyncConfig struct {
+	Dir           string `toml:"dir"`
+	Server        string `toml:"server"`
+	Token         string `toml:"token"`
+	EncryptionKey string `toml:"encryption_key"`
 }
 
 type FilterConfig struct {
@@ -39,13 +53,26 @@ var (
 	errDefaultModeNotEnabled    = errors.New("default_mode is not enabled")
 	errInvalidDefaultMode       = errors.New("invalid default_mode")
 	errInvalidDefaultFailFilter = errors.New("invalid default_fail_filter")
+	errInvalidCase              = errors.New("invalid case")
+	errInvalidFuzzyAlgorithm    = errors.New("invalid fuzzy_algorithm")
 	errInvalidMultilinePreview  = errors.New("invalid multiline_preview")
+	errInvalidRanking           = errors.New("invalid ranking")
+	errInvalidHalfLife          = errors.New("invalid half_life")
+	errInvalidLongDuration      = errors.New("invalid long_duration")
+	errInvalidProximityDecay    = errors.New("invalid proximity_decay")
 )
 
 func Default() Config {
 	return Config{
 		DB: DBConfig{
-			Path: "",
+			Path:               "",
+			CollapseDuplicates: false,
+		},
+		Sync: SyncConfig{
+			Dir:           "",
+			Server:        "",
+			Token:         "",
+			EncryptionKey: "",
 		},
 		Filters: FilterConfig{
 			IgnoreSpace:      true,
@@ -58,6 +85,7 @@ func Default() Config {
 		},
 		Theme:   DefaultTheme(),
 		Display: DefaultDisplay(),
+		Ranking: DefaultRanking(),
 		Keys:    DefaultKeys(),
 	}
 }
@@ -116,7 +144,22 @@ func (c Config) Validate() error {
 		return err
 	}
 
-	return c.validateMultilinePreview()
+	err = c.validateMultilinePreview()
+	if err != nil {
+		return err
+	}
+
+	err = c.validateCase()
+	if err != nil {
+		return err
+	}
+
+	err = c.validateFuzzyAlgorithm()
+	if err != nil {
+		return err
+	}
+
+	return c.validateRanking()
 }
 
 func (c Config) Save() error {
@@ -162,7 +205,7 @@ func (c Config) DatabasePath() (string, error) {
 }
 
 func (c Config) validateEnabledModes() error {
-	if !c.Display.EnableFuzzy && !c.Display.EnableRegex && !c.Display.EnableGlob {
+	if !c.Display.EnableFuzzy && !c.Display.EnableRegex && !c.Display.EnableGlob && !c.Display.EnableSubstring {
 		return errNoMatchModeEnabled
 	}
 
@@ -171,10 +214,14 @@ func (c Config) validateEnabledModes() error {
 
 func (c Config) validateDefaultScope() error {
 	switch c.Display.DefaultScope {
-	case "", "normal", "cwd":
+	case "", "normal", "global", "repo", "subtree", "exact", "cwd", "session", "host":
 		return nil
 	default:
-		return fmt.Errorf("%w %q: must be \"normal\" or \"cwd\"", errInvalidDefaultScope, c.Display.DefaultScope)
+		return fmt.Errorf(
+			"%w %q: must be \"global\", \"repo\", \"subtree\", \"exact\", \"session\", or \"host\"",
+			errInvalidDefaultScope,
+			c.Display.DefaultScope,
+		)
 	}
 }
 
@@ -197,9 +244,19 @@ func (c Config) validateDefaultMode() error {
 			return fmt.Errorf("%w: %q", errDefaultModeNotEnabled, c.Display.DefaultMode)
 		}
 
+		return nil
+	case "substring":
+		if !c.Display.EnableSubstring {
+			return fmt.Errorf("%w: %q", errDefaultModeNotEnabled, c.Display.DefaultMode)
+		}
+
 		return nil
 	default:
-		return fmt.Errorf("%w %q: must be \"fuzzy\", \"regex\", or \"glob\"", errInvalidDefaultMode, c.Display.DefaultMode)
+		return fmt.Errorf(
+			"%w %q: must be \"fuzzy\", \"substring\", \"regex\", or \"glob\"",
+			errInvalidDefaultMode,
+			c.Display.DefaultMode,
+		)
 	}
 }
 
@@ -227,3 +284,45 @@ func (c Config) validateMultilinePreview() error {
 		)
 	}
 }
+
+func (c Config) validateCase() error {
+	if _, ok := match.ParseCaseMode(c.Display.Case); ok {
+		return nil
+	}
+
+	return fmt.Errorf("%w %q: must be \"smart\", \"insensitive\", or \"sensitive\"", errInvalidCase, c.Display.Case)
+}
+
+func (c Config) validateFuzzyAlgorithm() error {
+	if _, ok := match.ParseFuzzyAlgorithm(c.Display.FuzzyAlgorithm); ok {
+		return nil
+	}
+
+	return fmt.Errorf("%w %q: must be \"v2\" or \"classic\"", errInvalidFuzzyAlgorithm, c.Display.FuzzyAlgorithm)
+}
+
+func (c Config) validateRanking() error {
+	switch c.Display.Ranking {
+	case "", "frecency", "recency", "match":
+	default:
+		return fmt.Errorf(
+			"%w %q: must be \"frecency\", \"recency\", or \"match\"",
+			errInvalidRanking,
+			c.Display.Ranking,
+		)
+	}
+
+	if c.Ranking.HalfLife <= 0 {
+		return fmt.Errorf("%w %s: must be positive", errInvalidHalfLife, c.Ranking.HalfLife)
+	}
+
+	if c.Ranking.LongDuration < 0 {
+		return fmt.Errorf("%w %s: must not be negative", errInvalidLongDuration, c.Ranking.LongDuration)
+	}
+
+	if c.Ranking.ProximityDecay < 0 || c.Ranking.ProximityDecay > 1 {
+		return fmt.Errorf("%w %v: must be between 0 and 1", errInvalidProximityDecay, c.Ranking.ProximityDecay)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func setTestHomes(t *testing.T, dir string) {
@@ -88,6 +89,11 @@ prompt = "$ "
 
 [display]
 default_fail_filter = "exclude"
+
+[ranking]
+half_life = "72h"
+cwd_bonus = 80
+failure_penalty = 25
 `
 	// #nosec G306 -- test file doesn't need restricted permissions
 	if err := os.WriteFile(filepath.Join(zgodDir, "config.toml"), []byte(tomlContent), 0o644); err != nil {
@@ -114,6 +120,22 @@ default_fail_filter = "exclude"
 	if cfg.Display.DefaultFailFilter != "exclude" {
 		t.Errorf("DefaultFailFilter = %q, want 'exclude'", cfg.Display.DefaultFailFilter)
 	}
+
+	if cfg.Ranking.HalfLife != 72*time.Hour {
+		t.Errorf("Ranking.HalfLife = %v, want 72h", cfg.Ranking.HalfLife)
+	}
+
+	if got := IntDefault(cfg.Ranking.CWDBonus, cfg.Display.CWDBoost); got != 80 {
+		t.Errorf("cwd_bonus = %d, want 80", got)
+	}
+
+	if cfg.Ranking.FailurePenalty != 25 {
+		t.Errorf("Ranking.FailurePenalty = %d, want 25", cfg.Ranking.FailurePenalty)
+	}
+
+	if cfg.Ranking.RecencyWeight != defaultRecencyWeight {
+		t.Errorf("Ranking.RecencyWeight = %v, want default %v", cfg.Ranking.RecencyWeight, defaultRecencyWeight)
+	}
 }
 
 func TestValidateDefaultFailFilter(t *testing.T) {
@@ -130,6 +152,47 @@ func TestValidateDefaultFailFilter(t *testing.T) {
 	}
 }
 
+func TestValidateCase(t *testing.T) {
+	cfg := Default()
+	cfg.Display.Case = "upper"
+
+	if err := cfg.Validate(); !errors.Is(err, errInvalidCase) {
+		t.Fatalf("Validate() error = %v, want errInvalidCase", err)
+	}
+}
+
+func TestValidateFuzzyAlgorithm(t *testing.T) {
+	cfg := Default()
+	cfg.Display.FuzzyAlgorithm = "v3"
+
+	if err := cfg.Validate(); !errors.Is(err, errInvalidFuzzyAlgorithm) {
+		t.Fatalf("Validate() error = %v, want errInvalidFuzzyAlgorithm", err)
+	}
+}
+
+func TestValidateRanking(t *testing.T) {
+	cfg := Default()
+	cfg.Display.Ranking = "popularity"
+
+	if err := cfg.Validate(); !errors.Is(err, errInvalidRanking) {
+		t.Fatalf("Validate() error = %v, want errInvalidRanking", err)
+	}
+
+	cfg = Default()
+	cfg.Ranking.HalfLife = 0
+
+	if err := cfg.Validate(); !errors.Is(err, errInvalidHalfLife) {
+		t.Fatalf("Validate() error = %v, want errInvalidHalfLife", err)
+	}
+
+	cfg = Default()
+	cfg.Ranking.ProximityDecay = 1.5
+
+	if err := cfg.Validate(); !errors.Is(err, errInvalidProximityDecay) {
+		t.Fatalf("Validate() error = %v, want errInvalidProximityDecay", err)
+	}
+}
+
 func TestDatabasePath(t *testing.T) {
 	cfg := Default()
 	cfg.DB.Path = ""
@@ -11,6 +11,7 @@ type DisplayConfig struct {
 	EnableFuzzy       bool   `toml:"enable_fuzzy"`
 	EnableRegex       bool   `toml:"enable_regex"`
 	EnableGlob        bool   `toml:"enable_glob"`
+	EnableSubstring   bool   `toml:"enable_substring"`
 	CWDBoost          int    `toml:"cwd_boost"`
 	DefaultScope      string `toml:"default_scope"`
 	DefaultMode       string `toml:"default_mode"`
@@ -18,6 +19,9 @@ type DisplayConfig struct {
 	HideMultiline     bool   `toml:"hide_multiline"`
 	MultilinePreview  string `toml:"multiline_preview"`
 	MultilineCollapse string `toml:"multiline_collapse"`
+	Ranking           string `toml:"ranking"`
+	Case              string `toml:"case"`
+	FuzzyAlgorithm    string `toml:"fuzzy_algorithm"`
 }
 
 func DefaultDisplay() DisplayConfig {
@@ -30,12 +34,16 @@ func DefaultDisplay() DisplayConfig {
 		EnableFuzzy:       true,
 		EnableRegex:       true,
 		EnableGlob:        true,
+		EnableSubstring:   true,
 		CWDBoost:          defaultCWDBoost,
-		DefaultScope:      "normal",
+		DefaultScope:      "global",
 		DefaultMode:       "fuzzy",
 		DefaultFailFilter: "include",
 		HideMultiline:     false,
 		MultilinePreview:  "popup",
 		MultilineCollapse: " ",
+		Ranking:           "frecency",
+		Case:              "smart",
+		FuzzyAlgorithm:    "classic",
 	}
 }
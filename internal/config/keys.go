@@ -5,7 +5,11 @@ type KeyConfig struct {
 	ModeFuzzy      string `toml:"mode_fuzzy"`
 	ModeRegex      string `toml:"mode_regex"`
 	ModeGlob       string `toml:"mode_glob"`
+	ModeSubstring  string `toml:"mode_substring"`
 	ToggleCWD      string `toml:"toggle_cwd"`
+	ToggleSession  string `toml:"toggle_session"`
+	ToggleHost     string `toml:"toggle_host"`
+	ToggleCase     string `toml:"toggle_case"`
 	ToggleDedupe   string `toml:"toggle_dedupe"`
 	ToggleFails    string `toml:"toggle_fails"`
 	Accept         string `toml:"accept"`
@@ -26,7 +30,11 @@ func DefaultKeys() KeyConfig {
 		ModeFuzzy:      "alt+f",
 		ModeRegex:      "alt+r",
 		ModeGlob:       "alt+g",
+		ModeSubstring:  "alt+e",
 		ToggleCWD:      "ctrl+d",
+		ToggleSession:  "alt+s",
+		ToggleHost:     "alt+h",
+		ToggleCase:     "alt+c",
 		ToggleDedupe:   "ctrl+g",
 		ToggleFails:    "ctrl+f",
 		Accept:         "enter",
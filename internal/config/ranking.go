@@ -0,0 +1,79 @@
+package config
+
+import "time"
+
+const (
+	defaultMatchWeight         = 1
+	defaultRecencyBase         = 10
+	defaultHalfLife            = 7 * 24 * time.Hour
+	defaultRecencyWeight       = 30
+	defaultFrequencyWeight     = 6
+	defaultSuccessWeight       = 10
+	defaultTransitionWeight    = 40
+	defaultSelectionWeight     = 30
+	defaultFailurePenalty      = 0
+	defaultLongDuration        = 5 * time.Minute
+	defaultLongDurationPenalty = 0
+	defaultSessionBonus        = 0
+	defaultHostBonus           = 0
+	defaultProximityDecay      = 0.5
+	defaultGitRootBonus        = 0
+)
+
+// RankingConfig holds the weights that order search results. Under frecency
+// ranking a command's recency weight halves every HalfLife, frequency grows
+// with the log of its run count and success scales with the share of runs
+// that exited 0. RecencyBase is the bonus for the newest entries under
+// recency ranking. TransitionWeight boosts commands that usually followed
+// the session's previous command and SelectionWeight those previously
+// chosen for similar queries. CWDBonus falls back to display.cwd_boost
+// when unset; it is scaled by ProximityDecay for every directory level
+// between an entry's directory and the current one, and GitRootBonus is
+// added for entries in the current git repository.
+type RankingConfig struct {
+	MatchWeight         float64       `toml:"match_weight"`
+	CWDBonus            *int          `toml:"cwd_bonus"`
+	ProximityDecay      float64       `toml:"proximity_decay"`
+	GitRootBonus        int           `toml:"git_root_bonus"`
+	RecencyBase         int           `toml:"recency_base"`
+	HalfLife            time.Duration `toml:"half_life"`
+	RecencyWeight       float64       `toml:"recency_weight"`
+	FrequencyWeight     float64       `toml:"frequency_weight"`
+	SuccessWeight       float64       `toml:"success_weight"`
+	TransitionWeight    float64       `toml:"transition_weight"`
+	SelectionWeight     float64       `toml:"selection_weight"`
+	FailurePenalty      int           `toml:"failure_penalty"`
+	LongDuration        time.Duration `toml:"long_duration"`
+	LongDurationPenalty int           `toml:"long_duration_penalty"`
+	SessionBonus        int           `toml:"session_bonus"`
+	HostBonus           int           `toml:"host_bonus"`
+}
+
+func DefaultRanking() RankingConfig {
+	return RankingConfig{
+		MatchWeight:         defaultMatchWeight,
+		CWDBonus:            nil,
+		ProximityDecay:      defaultProximityDecay,
+		GitRootBonus:        defaultGitRootBonus,
+		RecencyBase:         defaultRecencyBase,
+		HalfLife:            defaultHalfLife,
+		RecencyWeight:       defaultRecencyWeight,
+		FrequencyWeight:     defaultFrequencyWeight,
+		SuccessWeight:       defaultSuccessWeight,
+		TransitionWeight:    defaultTransitionWeight,
+		SelectionWeight:     defaultSelectionWeight,
+		FailurePenalty:      defaultFailurePenalty,
+		LongDuration:        defaultLongDuration,
+		LongDurationPenalty: defaultLongDurationPenalty,
+		SessionBonus:        defaultSessionBonus,
+		HostBonus:           defaultHostBonus,
+	}
+}
+
+func IntDefault(i *int, def int) int {
+	if i == nil {
+		return def
+	}
+
+	return *i
+}
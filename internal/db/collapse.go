@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// InsertCollapsed records entry, folding it into the previous row of the same
+// session when that row ran the identical command in the same directory.
+// The folded row is updated in place with the newest timestamp, duration and
+// exit code, keeping its ID and sync identity.
+func (r *HistoryRepo) InsertCollapsed(entry HistoryEntry) (int64, error) {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("starting collapse transaction: %w", err)
+	}
+
+	committed := false
+
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT `+historySelectColumns+`
+		 FROM history WHERE session_id = ?
+		 ORDER BY ts_ms DESC, id DESC LIMIT 1`,
+		entry.SessionID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("reading previous session entry: %w", err)
+	}
+
+	previous, err := scanEntries(rows)
+	_ = rows.Close()
+
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	if len(previous) == 1 && previous[0].Command == entry.Command && previous[0].Directory == entry.Directory {
+		prev := previous[0]
+		prev.TsMs = max(prev.TsMs, entry.TsMs)
+		prev.Duration = entry.Duration
+		prev.ExitCode = entry.ExitCode
+		prev.RunCount = prev.runCount() + entry.runCount()
+
+		id, err = prev.ID, updateEntryTx(tx, prev)
+	} else {
+		id, err = insertEntry(tx, entry)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing collapse transaction: %w", err)
+	}
+
+	committed = true
+
+	return id, nil
+}
+
+// CompactDuplicates folds existing runs of consecutive identical commands
+// within a session and directory into single rows and returns the number of
+// rows removed.
+func (r *HistoryRepo) CompactDuplicates() (int, error) {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("starting compact transaction: %w", err)
+	}
+
+	committed := false
+
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	entries, err := listBySessionTx(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+
+	for start := 0; start < len(entries); {
+		head := entries[start]
+
+		end := start + 1
+		for end < len(entries) && sameRun(head, entries[end]) {
+			end++
+		}
+
+		if end-start > 1 {
+			if err = collapseGroupTx(tx, head, entries[start+1:end]); err != nil {
+				return 0, err
+			}
+
+			removed += end - start - 1
+		}
+
+		start = end
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing compact transaction: %w", err)
+	}
+
+	committed = true
+
+	return removed, nil
+}
+
+func sameRun(a HistoryEntry, b HistoryEntry) bool {
+	return a.SessionID == b.SessionID &&
+		a.Command == b.Command &&
+		a.Directory == b.Directory
+}
+
+// collapseGroupTx folds the later rows of a run into its first row, which
+// takes the newest timestamp, duration and exit code like InsertCollapsed.
+func collapseGroupTx(tx *sql.Tx, head HistoryEntry, folded []HistoryEntry) error {
+	ctx := context.Background()
+
+	for _, e := range folded {
+		head.TsMs = max(head.TsMs, e.TsMs)
+		head.Duration = e.Duration
+		head.ExitCode = e.ExitCode
+		head.RunCount = head.runCount() + e.runCount()
+
+		_, err := tx.ExecContext(ctx, `UPDATE selections SET history_id = ? WHERE history_id = ?`, head.ID, e.ID)
+		if err != nil {
+			return fmt.Errorf("moving selections of history entry %d: %w", e.ID, err)
+		}
+
+		if err = deleteEntryTx(tx, e); err != nil {
+			return err
+		}
+	}
+
+	return updateEntryTx(tx, head)
+}
+
+// updateEntryTx stores e's timestamp, duration, exit code and run count in
+// place and queues the row for sync.
+func updateEntryTx(tx *sql.Tx, e HistoryEntry) error {
+	ctx := context.Background()
+
+	_, err := tx.ExecContext(
+		ctx,
+		`UPDATE history SET ts_ms = ?, duration = ?, exit_code = ?, run_count = ? WHERE id = ?`,
+		e.TsMs, e.Duration, e.ExitCode, e.runCount(), e.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("updating history entry %d: %w", e.ID, err)
+	}
+
+	tracked, err := syncTrackedTx(tx)
+	if err != nil || !tracked {
+		return err
+	}
+
+	// Replacing the marker gives it a new ID past every sync cursor.
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO sync_updates (history_id) VALUES (?)`, e.ID)
+	if err != nil {
+		return fmt.Errorf("queueing history entry %d for sync: %w", e.ID, err)
+	}
+
+	return nil
+}
+
+// deleteEntryTx removes e along with its selections once no other row keeps
+// the command. Once sync is in use, its sync key is recorded so the deletion
+// reaches other machines.
+func deleteEntryTx(tx *sql.Tx, e HistoryEntry) error {
+	ctx := context.Background()
+
+	tracked, err := syncTrackedTx(tx)
+	if err != nil {
+		return err
+	}
+
+	if tracked {
+		if _, err = tx.ExecContext(
+			ctx,
+			`INSERT OR IGNORE INTO sync_tombstones (ts_ms, command, directory, session_id, hostname, remote)
+			 VALUES (?, ?, ?, ?, ?, 0)`,
+			e.SyncKey().args()...,
+		); err != nil {
+			return fmt.Errorf("recording deletion of history entry %d: %w", e.ID, err)
+		}
+	}
+
+	for _, table := range []string{"sync_merged", "sync_updates"} {
+		if _, err = tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE history_id = ?`, e.ID); err != nil {
+			return fmt.Errorf("clearing %s for history entry %d: %w", table, e.ID, err)
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM history WHERE id = ?`, e.ID); err != nil {
+		return fmt.Errorf("deleting history entry %d: %w", e.ID, err)
+	}
+
+	return forgetSelectionsTx(tx, e.Command)
+}
+
+// syncTrackedTx reports whether sync has ever run against this database.
+// Until it has, every row is still new to other machines, so changes need no
+// tombstones or update markers.
+func syncTrackedTx(tx *sql.Tx) (bool, error) {
+	var tracked bool
+
+	err := tx.QueryRowContext(context.Background(), `SELECT EXISTS (SELECT 1 FROM sync_state)`).Scan(&tracked)
+	if err != nil {
+		return false, fmt.Errorf("checking sync state: %w", err)
+	}
+
+	return tracked, nil
+}
+
+func listBySessionTx(tx *sql.Tx) ([]HistoryEntry, error) {
+	rows, err := tx.QueryContext(
+		context.Background(),
+		`SELECT `+historySelectColumns+`
+		 FROM history
+		 ORDER BY session_id ASC, ts_ms ASC, id ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying history by session: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	return scanEntries(rows)
+}
@@ -30,11 +30,22 @@ const (
 )
 
 func Open(dbPath string) (*sql.DB, error) {
+	return openWithSchema(dbPath, ensureSchema)
+}
+
+// openWithSchema opens a writable database in WAL mode and applies the
+// schema set up by ensure.
+func openWithSchema(dbPath string, ensure func(*sql.DB) error) (*sql.DB, error) {
 	if err := ensureFilePermissions(dbPath, 0o600); err != nil {
 		return nil, fmt.Errorf("ensuring database file permissions for %q: %w", dbPath, err)
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	dsn, err := sqliteWritableDSN(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("building sqlite DSN for %q: %w", dbPath, err)
+	}
+
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("opening sqlite database %q: %w", dbPath, err)
 	}
@@ -53,7 +64,7 @@ func Open(dbPath string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	if err = ensureSchema(db); err != nil {
+	if err = ensure(db); err != nil {
 		_ = db.Close()
 		return nil, fmt.Errorf("ensuring database schema: %w", err)
 	}
@@ -89,6 +100,27 @@ func OpenReadOnly(dbPath string) (*sql.DB, error) {
 }
 
 func sqliteReadOnlyDSN(dbPath string) (string, error) {
+	query := url.Values{}
+	query.Set("mode", "ro")
+	query.Add("_pragma", "query_only(ON)")
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", sqliteBusyTimeoutMs))
+	query.Add("_pragma", "foreign_keys(ON)")
+
+	return sqliteDSN(dbPath, query)
+}
+
+// sqliteWritableDSN starts read-write transactions with BEGIN IMMEDIATE, so
+// a transaction that reads before writing waits for the write lock up front
+// instead of failing with SQLITE_BUSY when another writer got there first.
+func sqliteWritableDSN(dbPath string) (string, error) {
+	query := url.Values{}
+	query.Set("_txlock", "immediate")
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", sqliteBusyTimeoutMs))
+
+	return sqliteDSN(dbPath, query)
+}
+
+func sqliteDSN(dbPath string, query url.Values) (string, error) {
 	absolutePath, err := filepath.Abs(dbPath)
 	if err != nil {
 		return "", fmt.Errorf("building absolute path: %w", err)
@@ -99,12 +131,6 @@ func sqliteReadOnlyDSN(dbPath string) (string, error) {
 		uriPath = "/" + uriPath
 	}
 
-	query := url.Values{}
-	query.Set("mode", "ro")
-	query.Add("_pragma", "query_only(ON)")
-	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", sqliteBusyTimeoutMs))
-	query.Add("_pragma", "foreign_keys(ON)")
-
 	return (&url.URL{
 		Scheme:   "file",
 		Path:     uriPath,
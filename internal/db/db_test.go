@@ -6,9 +6,11 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestOpenAndInsert(t *testing.T) {
@@ -161,7 +163,7 @@ func TestFetchCandidatesDedupe(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	entries, _ := repo.FetchCandidates(100, true, FailFilterInclude)
+	entries, _ := repo.FetchCandidates(100, true, CandidateFilter{FailFilter: FailFilterInclude})
 	if len(entries) != 2 {
 		t.Errorf("FetchCandidates(dedupe=true) returned %d entries, want 2", len(entries))
 	}
@@ -220,7 +222,7 @@ func TestFetchCandidatesFailFilterModes(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			got, fetchErr := repo.FetchCandidates(100, tc.dedupe, tc.mode)
+			got, fetchErr := repo.FetchCandidates(100, tc.dedupe, CandidateFilter{FailFilter: tc.mode})
 			if fetchErr != nil {
 				t.Fatalf("FetchCandidates() error: %v", fetchErr)
 			}
@@ -261,7 +263,7 @@ func TestFetchCandidatesAppliesDedupeAfterFailFilter(t *testing.T) {
 		}
 	}
 
-	got, err := repo.FetchCandidates(100, true, FailFilterOnly)
+	got, err := repo.FetchCandidates(100, true, CandidateFilter{FailFilter: FailFilterOnly})
 	if err != nil {
 		t.Fatalf("FetchCandidates() error: %v", err)
 	}
@@ -679,3 +681,834 @@ func TestIsBusyErrorReturnsFalseForNonBusyError(t *testing.T) {
 		t.Fatal("IsBusyError() = true, want false for non-busy error")
 	}
 }
+
+func TestOpenMigratesLegacySchemaRunCount(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	legacy, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("sql.Open() error: %v", err)
+	}
+
+	_, err = legacy.ExecContext(
+		context.Background(),
+		`CREATE TABLE history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts_ms INTEGER NOT NULL,
+			duration INTEGER NOT NULL DEFAULT 0,
+			exit_code INTEGER NOT NULL DEFAULT 0,
+			command TEXT NOT NULL,
+			directory TEXT NOT NULL DEFAULT '',
+			session_id TEXT NOT NULL DEFAULT '',
+			hostname TEXT NOT NULL DEFAULT ''
+		);
+		INSERT INTO history (ts_ms, command) VALUES (1000, 'echo legacy');`,
+	)
+	if err != nil {
+		_ = legacy.Close()
+
+		t.Fatalf("creating legacy schema: %v", err)
+	}
+
+	if err = legacy.Close(); err != nil {
+		t.Fatalf("Close(legacy) error: %v", err)
+	}
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	entries, err := NewHistoryRepo(database).Recent(10)
+	if err != nil {
+		t.Fatalf("Recent() error: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].RunCount != 1 || entries[0].Shell != "" || entries[0].FirstTsMs != 1000 {
+		t.Fatalf("Recent() = %+v, want one entry with RunCount 1, no shell and FirstTsMs 1000", entries)
+	}
+}
+
+func TestInsertCollapsedFoldsConsecutiveDuplicates(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+	entries := []HistoryEntry{
+		{TsMs: 1000, Command: "make test", Directory: "/src", SessionID: "s1"},
+		{TsMs: 2000, ExitCode: 2, Command: "make test", Directory: "/src", SessionID: "s1"},
+		{TsMs: 2500, Command: "make test", Directory: "/src", SessionID: "s2"},
+		{TsMs: 3000, Command: "make test", Directory: "/other", SessionID: "s1"},
+		{TsMs: 4000, Command: "ls", Directory: "/other", SessionID: "s1"},
+		{TsMs: 5000, Command: "make test", Directory: "/other", SessionID: "s1"},
+	}
+
+	for _, entry := range entries {
+		if _, err = repo.InsertCollapsed(entry); err != nil {
+			t.Fatalf("InsertCollapsed(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	got, err := repo.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("ListAll() returned %d entries, want 5", len(got))
+	}
+
+	first := got[0]
+	if first.TsMs != 2000 || first.RunCount != 2 || first.ExitCode != 2 {
+		t.Fatalf("collapsed entry = %+v, want ts=2000 run_count=2 exit_code=2", first)
+	}
+
+	for _, e := range got[1:] {
+		if e.RunCount != 1 {
+			t.Fatalf("entry %+v has RunCount %d, want 1", e, e.RunCount)
+		}
+	}
+}
+
+func TestInsertCollapsedWaitsForConcurrentWriter(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	writer, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = writer.Close() }()
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+
+	tx, err := writer.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error: %v", err)
+	}
+
+	if _, err = insertEntry(tx, HistoryEntry{TsMs: 1000, Command: "make", Directory: "/src", SessionID: "s1"}); err != nil {
+		t.Fatalf("insertEntry() error: %v", err)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := repo.InsertCollapsed(HistoryEntry{TsMs: 2000, Command: "make", Directory: "/src", SessionID: "s1"})
+		done <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+
+	if err = <-done; err != nil {
+		t.Fatalf("InsertCollapsed() error: %v", err)
+	}
+
+	got, err := repo.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	if len(got) != 1 || got[0].TsMs != 2000 || got[0].RunCount != 2 {
+		t.Fatalf("ListAll() = %+v, want one entry at 2000 with RunCount 2", got)
+	}
+}
+
+func countRows(t *testing.T, database *sql.DB, table string) int {
+	t.Helper()
+
+	var n int
+	if err := database.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM `+table).Scan(&n); err != nil {
+		t.Fatalf("counting %s rows: %v", table, err)
+	}
+
+	return n
+}
+
+func TestInsertCollapsedUpdatesInPlace(t *testing.T) {
+	database, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+	entry := HistoryEntry{TsMs: 1000, Command: "ls", Directory: "/src", SessionID: "s1"}
+
+	firstID, err := repo.InsertCollapsed(entry)
+	if err != nil {
+		t.Fatalf("InsertCollapsed() error: %v", err)
+	}
+
+	for i := range 100 {
+		entry.TsMs = int64(2000 + i)
+
+		id, insertErr := repo.InsertCollapsed(entry)
+		if insertErr != nil || id != firstID {
+			t.Fatalf("InsertCollapsed() = %d, %v, want ID %d", id, insertErr, firstID)
+		}
+	}
+
+	if n := countRows(t, database, "sync_tombstones") + countRows(t, database, "sync_updates"); n != 0 {
+		t.Fatalf("sync bookkeeping has %d rows before sync is used, want 0", n)
+	}
+
+	if err = SetSyncPosition(database, "target:dir:history", firstID); err != nil {
+		t.Fatalf("SetSyncPosition() error: %v", err)
+	}
+
+	for range 10 {
+		entry.TsMs++
+		if _, err = repo.InsertCollapsed(entry); err != nil {
+			t.Fatalf("InsertCollapsed() error: %v", err)
+		}
+	}
+
+	if n := countRows(t, database, "sync_tombstones"); n != 0 {
+		t.Fatalf("sync_tombstones has %d rows, want 0", n)
+	}
+
+	if n := countRows(t, database, "sync_updates"); n != 1 {
+		t.Fatalf("sync_updates has %d rows, want 1", n)
+	}
+
+	got, err := repo.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	if len(got) != 1 || got[0].RunCount != 111 || got[0].TsMs != entry.TsMs || got[0].FirstTsMs != 1000 {
+		t.Fatalf("ListAll() = %+v, want one entry with 111 runs first run at 1000", got)
+	}
+}
+
+func TestCompactDuplicatesWritesNoTombstonesBeforeSync(t *testing.T) {
+	database, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+	for i := range 50 {
+		if _, err = repo.Insert(HistoryEntry{TsMs: int64(1000 + i), Command: "ls", SessionID: "s1"}); err != nil {
+			t.Fatalf("Insert() error: %v", err)
+		}
+	}
+
+	if removed, compactErr := repo.CompactDuplicates(); compactErr != nil || removed != 49 {
+		t.Fatalf("CompactDuplicates() = %d, %v, want 49 removed", removed, compactErr)
+	}
+
+	if n := countRows(t, database, "sync_tombstones"); n != 0 {
+		t.Fatalf("sync_tombstones has %d rows, want 0", n)
+	}
+}
+
+func TestCompactDuplicates(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+	entries := []HistoryEntry{
+		{TsMs: 1000, Command: "make test", Directory: "/src", SessionID: "s1"},
+		{TsMs: 1500, Command: "git status", Directory: "/src", SessionID: "s2"},
+		{TsMs: 2000, Command: "make test", Directory: "/src", SessionID: "s1"},
+		{TsMs: 3000, Duration: 7, Command: "make test", Directory: "/src", SessionID: "s1"},
+		{TsMs: 4000, Command: "ls", Directory: "/src", SessionID: "s1"},
+		{TsMs: 5000, Command: "make test", Directory: "/src", SessionID: "s1"},
+	}
+
+	for _, entry := range entries {
+		if _, err = repo.Insert(entry); err != nil {
+			t.Fatalf("Insert(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	removed, err := repo.CompactDuplicates()
+	if err != nil {
+		t.Fatalf("CompactDuplicates() error: %v", err)
+	}
+
+	if removed != 2 {
+		t.Fatalf("CompactDuplicates() removed %d rows, want 2", removed)
+	}
+
+	got, err := repo.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	if len(got) != 4 {
+		t.Fatalf("ListAll() returned %d entries, want 4", len(got))
+	}
+
+	if got[0].Command != "git status" {
+		t.Fatalf("ListAll()[0].Command = %q, want %q", got[0].Command, "git status")
+	}
+
+	collapsed := got[1]
+	if collapsed.Command != "make test" || collapsed.TsMs != 3000 || collapsed.Duration != 7 || collapsed.RunCount != 3 {
+		t.Fatalf("collapsed entry = %+v, want make test ts=3000 duration=7 run_count=3", collapsed)
+	}
+
+	removed, err = repo.CompactDuplicates()
+	if err != nil {
+		t.Fatalf("CompactDuplicates() second run error: %v", err)
+	}
+
+	if removed != 0 {
+		t.Fatalf("CompactDuplicates() second run removed %d rows, want 0", removed)
+	}
+}
+
+func TestReadAtuinHistory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "atuin.db")
+
+	source, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("sql.Open() error: %v", err)
+	}
+
+	defer func() { _ = source.Close() }()
+
+	_, err = source.ExecContext(
+		context.Background(),
+		`CREATE TABLE history (
+			id TEXT PRIMARY KEY,
+			timestamp INTEGER NOT NULL,
+			duration INTEGER NOT NULL,
+			exit INTEGER NOT NULL,
+			command TEXT NOT NULL,
+			cwd TEXT NOT NULL,
+			session TEXT NOT NULL,
+			hostname TEXT NOT NULL,
+			deleted_at INTEGER
+		);
+		INSERT INTO history VALUES
+			('a', 1700000000123456789, 2500000000, 0, 'cargo build', '/src', 'sess-1', 'laptop:alice', NULL),
+			('b', 1700000001000000000, -1, -1, 'sleep 100', '/tmp', 'sess-1', 'laptop:alice', NULL),
+			('c', 1700000002000000000, 1000000, 1, 'rm secret', '/tmp', 'sess-2', 'laptop:alice', 1700000003000000000);`,
+	)
+	if err != nil {
+		t.Fatalf("creating atuin schema: %v", err)
+	}
+
+	entries, err := ReadAtuinHistory(source)
+	if err != nil {
+		t.Fatalf("ReadAtuinHistory() error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("ReadAtuinHistory() returned %d entries, want 2", len(entries))
+	}
+
+	first := entries[0]
+	if first.TsMs != 1_700_000_000_123 || first.Duration != 2500 || first.Directory != "/src" ||
+		first.SessionID != "sess-1" || first.Hostname != "laptop" {
+		t.Fatalf("entries[0] = %+v", first)
+	}
+
+	if entries[1].ExitCode != 0 || entries[1].Duration != 0 {
+		t.Fatalf("entries[1] = %+v, want unknown exit and duration mapped to 0", entries[1])
+	}
+}
+
+func TestReadMcflyHistory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "mcfly.db")
+
+	source, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("sql.Open() error: %v", err)
+	}
+
+	defer func() { _ = source.Close() }()
+
+	_, err = source.ExecContext(
+		context.Background(),
+		`CREATE TABLE commands (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			cmd TEXT NOT NULL,
+			cmd_tpl TEXT,
+			session_id TEXT NOT NULL,
+			when_run INTEGER NOT NULL,
+			exit_code INTEGER NOT NULL,
+			selected INTEGER NOT NULL,
+			dir TEXT,
+			old_dir TEXT
+		);
+		INSERT INTO commands (cmd, session_id, when_run, exit_code, selected, dir) VALUES
+			('make', 'm-1', 1700000005, 2, 0, '/src'),
+			('ls', 'm-1', 1700000001, 0, 0, NULL);`,
+	)
+	if err != nil {
+		t.Fatalf("creating mcfly schema: %v", err)
+	}
+
+	entries, err := ReadMcflyHistory(source)
+	if err != nil {
+		t.Fatalf("ReadMcflyHistory() error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("ReadMcflyHistory() returned %d entries, want 2", len(entries))
+	}
+
+	if entries[0].Command != "ls" || entries[0].TsMs != 1_700_000_001_000 || entries[0].Directory != "" {
+		t.Fatalf("entries[0] = %+v", entries[0])
+	}
+
+	if entries[1].ExitCode != 2 || entries[1].SessionID != "m-1" || entries[1].Directory != "/src" {
+		t.Fatalf("entries[1] = %+v", entries[1])
+	}
+}
+
+func TestReadAtuinHistoryRejectsOtherSchemas(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "zgod.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	if _, err = ReadAtuinHistory(database); !errors.Is(err, errTableColumnsMissing) {
+		t.Fatalf("ReadAtuinHistory() error = %v, want errTableColumnsMissing", err)
+	}
+}
+
+func TestExportAppliesFilter(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+	entries := []HistoryEntry{
+		{TsMs: 1000, Command: "too old", Directory: "/src", Hostname: "laptop"},
+		{TsMs: 2000, Command: "wanted", Directory: "/src", Hostname: "laptop"},
+		{TsMs: 2500, ExitCode: 1, Command: "failed", Directory: "/src", Hostname: "laptop"},
+		{TsMs: 3000, Command: "other host", Directory: "/src", Hostname: "server"},
+		{TsMs: 3500, Command: "other dir", Directory: "/tmp", Hostname: "laptop"},
+		{TsMs: 4000, Command: "too new", Directory: "/src", Hostname: "laptop"},
+	}
+
+	for _, entry := range entries {
+		if _, err = repo.Insert(entry); err != nil {
+			t.Fatalf("Insert(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	filter := ExportFilter{
+		SinceMs:    2000,
+		UntilMs:    4000,
+		Hostname:   "laptop",
+		Directory:  "/src",
+		FailFilter: FailFilterExclude,
+	}
+
+	var got []string
+
+	err = repo.Export(filter, func(e HistoryEntry) error {
+		got = append(got, e.Command)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+
+	if len(got) != 1 || got[0] != "wanted" {
+		t.Fatalf("Export() = %v, want [wanted]", got)
+	}
+}
+
+func TestFetchCandidatesAppliesCandidateFilter(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	src := filepath.Join(string(filepath.Separator), "src")
+	repo := NewHistoryRepo(database)
+	entries := []HistoryEntry{
+		{TsMs: 1000, Duration: 60_000, Command: "too old", Directory: src},
+		{TsMs: 2000, Duration: 45_000, ExitCode: 2, Command: "wanted", Directory: filepath.Join(src, "api")},
+		{TsMs: 2100, Duration: 45_000, ExitCode: 2, Command: "sibling dir", Directory: src + "-old"},
+		{TsMs: 2200, Duration: 10, ExitCode: 2, Command: "too fast", Directory: src},
+		{TsMs: 2300, Duration: 45_000, Command: "succeeded", Directory: src},
+		{TsMs: 2400, Duration: 45_000, ExitCode: 2, Command: "other host", Directory: src, Hostname: "ci"},
+	}
+
+	for _, entry := range entries {
+		if _, err = repo.Insert(entry); err != nil {
+			t.Fatalf("Insert(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	filter := CandidateFilter{
+		Directory: src,
+		SinceMs:   1500,
+		UntilMs:   3000,
+		ExitCode:  []Comparison{{Op: CompareNe, Value: 0}},
+		Duration:  []Comparison{{Op: CompareGt, Value: 30_000}},
+		Hostname:  "",
+	}
+
+	got, err := repo.FetchCandidates(100, false, filter)
+	if err != nil {
+		t.Fatalf("FetchCandidates() error: %v", err)
+	}
+
+	if len(got) != 2 || got[0].Command != "other host" || got[1].Command != "wanted" {
+		t.Fatalf("FetchCandidates() = %+v, want [other host wanted]", got)
+	}
+
+	filter.Scope = Scope{Directory: src, Exact: true}
+
+	got, err = repo.FetchCandidates(100, false, filter)
+	if err != nil {
+		t.Fatalf("FetchCandidates() with exact scope error: %v", err)
+	}
+
+	if len(got) != 1 || got[0].Command != "other host" {
+		t.Fatalf("FetchCandidates() with exact scope = %+v, want [other host]", got)
+	}
+
+	filter.Scope = Scope{Directory: src, Hostname: "laptop"}
+
+	got, err = repo.FetchCandidates(100, false, filter)
+	if err != nil {
+		t.Fatalf("FetchCandidates() with host scope error: %v", err)
+	}
+
+	if len(got) != 0 {
+		t.Fatalf("FetchCandidates() with host scope = %+v, want none", got)
+	}
+}
+
+func TestFetchCandidatesMatchesNonASCIISubtree(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	home := filepath.Join(string(filepath.Separator), "home", "žan")
+	repo := NewHistoryRepo(database)
+
+	for _, entry := range []HistoryEntry{
+		{TsMs: 1000, Command: "below", Directory: filepath.Join(home, "projekt")},
+		{TsMs: 2000, Command: "sibling", Directory: home + "ek"},
+	} {
+		if _, err = repo.Insert(entry); err != nil {
+			t.Fatalf("Insert(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	got, err := repo.FetchCandidates(100, false, CandidateFilter{Directory: home})
+	if err != nil {
+		t.Fatalf("FetchCandidates() error: %v", err)
+	}
+
+	if len(got) != 1 || got[0].Command != "below" {
+		t.Fatalf("FetchCandidates() = %+v, want [below]", got)
+	}
+}
+
+func TestCommandStatsCountsRunsAndSuccesses(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+	entries := []HistoryEntry{
+		{TsMs: 1000, Command: "make", RunCount: 3},
+		{TsMs: 2000, ExitCode: 2, Command: "make"},
+		{TsMs: 3000, Command: "ls"},
+	}
+
+	for _, entry := range entries {
+		if _, err = repo.Insert(entry); err != nil {
+			t.Fatalf("Insert(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	stats, err := repo.CommandStats(CandidateFilter{})
+	if err != nil {
+		t.Fatalf("CommandStats() error: %v", err)
+	}
+
+	if got, want := stats["make"], (CommandStats{Runs: 4, Successes: 3}); got != want {
+		t.Fatalf("stats[make] = %+v, want %+v", got, want)
+	}
+
+	if got, want := stats["ls"], (CommandStats{Runs: 1, Successes: 1}); got != want {
+		t.Fatalf("stats[ls] = %+v, want %+v", got, want)
+	}
+}
+
+func TestTransitionsFollowSessionOrder(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+	entries := []HistoryEntry{
+		{TsMs: 1000, Command: "git add .", SessionID: "a"},
+		{TsMs: 1500, Command: "ls", SessionID: "b"},
+		{TsMs: 2000, Command: "git commit", SessionID: "a"},
+		{TsMs: 3000, Command: "git add .", SessionID: "b"},
+		{TsMs: 4000, Command: "git commit", SessionID: "b"},
+		{TsMs: 5000, Command: "git add .", SessionID: "a"},
+		{TsMs: 6000, Command: "git status", SessionID: "a"},
+		{TsMs: 7000, Command: "git add .", SessionID: "c"},
+	}
+
+	for _, entry := range entries {
+		if _, err = repo.Insert(entry); err != nil {
+			t.Fatalf("Insert(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	got, err := repo.Transitions("git add .")
+	if err != nil {
+		t.Fatalf("Transitions() error: %v", err)
+	}
+
+	want := map[string]int{"git commit": 2, "git status": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Transitions() = %v, want %v", got, want)
+	}
+
+	last, ok, err := repo.LastSessionCommand("b")
+	if err != nil || !ok || last != "git commit" {
+		t.Fatalf("LastSessionCommand(b) = %q, %v, %v; want git commit", last, ok, err)
+	}
+
+	if _, ok, err = repo.LastSessionCommand("missing"); err != nil || ok {
+		t.Fatalf("LastSessionCommand(missing) = %v, %v; want not found", ok, err)
+	}
+}
+
+func TestTransitionsUseSessionIndex(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	plan := queryPlan(t, database, transitionsQuery, "make")
+	if !strings.Contains(plan, "idx_history_session_ts") || strings.Contains(plan, "TEMP B-TREE FOR ORDER BY") {
+		t.Fatalf("query plan does not walk idx_history_session_ts:\n%s", plan)
+	}
+}
+
+// queryPlan returns the EXPLAIN QUERY PLAN details of query, one per line.
+func queryPlan(t *testing.T, database *sql.DB, query string, args ...any) string {
+	t.Helper()
+
+	rows, err := database.QueryContext(context.Background(), "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN error: %v", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var plan []string
+
+	for rows.Next() {
+		var (
+			id, parent, unused int
+			detail             string
+		)
+
+		if err = rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatalf("Scan() error: %v", err)
+		}
+
+		plan = append(plan, detail)
+	}
+
+	return strings.Join(plan, "\n")
+}
+
+func TestSelectionCountsUseQueryIndex(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	where, args := selectionQueryMatch("gït")
+	if plan := queryPlan(t, database, "SELECT command FROM selections WHERE "+where, args...); strings.Contains(plan, "SCAN selections") {
+		t.Fatalf("selection lookup scans the table:\n%s", plan)
+	}
+
+	repo := NewHistoryRepo(database)
+	for _, s := range []Selection{
+		{TsMs: 1, Query: "gï", Command: "gït log"},
+		{TsMs: 2, Query: "gït lo", Command: "gït log"},
+		{TsMs: 3, Query: "gït", Command: "gït log"},
+		{TsMs: 4, Query: "gïx", Command: "other"},
+	} {
+		if err = repo.RecordSelection(s); err != nil {
+			t.Fatalf("RecordSelection(%q) error: %v", s.Query, err)
+		}
+	}
+
+	got, err := repo.SelectionCounts("gït")
+	if err != nil {
+		t.Fatalf("SelectionCounts() error: %v", err)
+	}
+
+	if want := map[string]int{"gït log": 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("SelectionCounts(gït) = %v, want %v", got, want)
+	}
+}
+
+func TestRemoteDeleteForgetsSelections(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+	entry := HistoryEntry{TsMs: 1000, Command: "make", Hostname: "a"}
+
+	if _, err = repo.Insert(entry); err != nil {
+		t.Fatalf("Insert() error: %v", err)
+	}
+
+	if err = repo.RecordSelection(Selection{TsMs: 1, Query: "ma", Command: "make"}); err != nil {
+		t.Fatalf("RecordSelection() error: %v", err)
+	}
+
+	tx, err := database.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error: %v", err)
+	}
+
+	if _, err = ApplyRemoteDeleteTx(tx, entry.SyncKey()); err != nil {
+		t.Fatalf("ApplyRemoteDeleteTx() error: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+
+	if got, err := repo.SelectionCounts("ma"); err != nil || len(got) != 0 {
+		t.Fatalf("SelectionCounts(ma) = %v, %v, want none", got, err)
+	}
+}
+
+func TestSelectionsCountSimilarQueriesAndPositions(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := NewHistoryRepo(database)
+	selections := []Selection{
+		{TsMs: 1, Query: "git", Mode: "fuzzy", Command: "git status", Position: 0},
+		{TsMs: 2, Query: "git st", Mode: "fuzzy", Command: "git status", Position: 2},
+		{TsMs: 3, Query: "gi", Mode: "fuzzy", Command: "git push", Position: 0},
+		{TsMs: 4, Query: "ls", Mode: "fuzzy", Command: "ls -la", Position: 12},
+		{TsMs: 5, Query: "", Mode: "fuzzy", Command: "make", Position: 1},
+	}
+
+	for _, s := range selections {
+		if err = repo.RecordSelection(s); err != nil {
+			t.Fatalf("RecordSelection(%q) error: %v", s.Query, err)
+		}
+	}
+
+	got, err := repo.SelectionCounts("git")
+	if err != nil {
+		t.Fatalf("SelectionCounts() error: %v", err)
+	}
+
+	if want := map[string]int{"git status": 2, "git push": 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("SelectionCounts(git) = %v, want %v", got, want)
+	}
+
+	got, err = repo.SelectionCounts("")
+	if err != nil {
+		t.Fatalf("SelectionCounts() error: %v", err)
+	}
+
+	if want := map[string]int{"make": 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("SelectionCounts(\"\") = %v, want %v", got, want)
+	}
+
+	positions, err := repo.SelectionPositions()
+	if err != nil {
+		t.Fatalf("SelectionPositions() error: %v", err)
+	}
+
+	if want := map[int]int{0: 2, 1: 1, 2: 1, 12: 1}; !reflect.DeepEqual(positions, want) {
+		t.Fatalf("SelectionPositions() = %v, want %v", positions, want)
+	}
+}
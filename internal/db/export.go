@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ExportFilter selects the history rows written by an export. Zero values
+// leave the corresponding field unfiltered; UntilMs is exclusive.
+type ExportFilter struct {
+	SinceMs    int64
+	UntilMs    int64
+	Hostname   string
+	Directory  string
+	SessionID  string
+	FailFilter FailFilterMode
+}
+
+func (f ExportFilter) where() (string, []any) {
+	w := whereBuilder{query: "", args: []any{}}
+
+	if f.SinceMs > 0 {
+		w.add("ts_ms >= ?", f.SinceMs)
+	}
+
+	if f.UntilMs > 0 {
+		w.add("ts_ms < ?", f.UntilMs)
+	}
+
+	if f.Hostname != "" {
+		w.add("hostname = ?", f.Hostname)
+	}
+
+	if f.Directory != "" {
+		w.add("directory = ?", f.Directory)
+	}
+
+	if f.SessionID != "" {
+		w.add("session_id = ?", f.SessionID)
+	}
+
+	w.addFailFilter(f.FailFilter)
+
+	return w.query, w.args
+}
+
+// Export streams the entries matching filter to fn in chronological order
+// without loading the whole history into memory.
+func (r *HistoryRepo) Export(filter ExportFilter, fn func(HistoryEntry) error) error {
+	where, args := filter.where()
+
+	rows, err := r.db.QueryContext(
+		context.Background(),
+		`SELECT `+historySelectColumns+`
+		 FROM history`+where+`
+		 ORDER BY ts_ms ASC, id ASC`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("querying history for export: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	return eachEntry(rows, fn)
+}
+
+func eachEntry(rows *sql.Rows, fn func(HistoryEntry) error) error {
+	for rows.Next() {
+		var e HistoryEntry
+
+		err := rows.Scan(&e.ID, &e.TsMs, &e.Duration, &e.ExitCode,
+			&e.Command, &e.Directory, &e.SessionID, &e.Hostname, &e.RunCount, &e.Shell, &e.FirstTsMs)
+		if err != nil {
+			return fmt.Errorf("scanning history row: %w", err)
+		}
+
+		if err = fn(e); err != nil {
+			return err
+		}
+	}
+
+	err := rows.Err()
+	if err != nil {
+		return fmt.Errorf("iterating history rows: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// CompareOp is a comparison applied to a numeric column.
+type CompareOp string
+
+const (
+	CompareEq CompareOp = "="
+	CompareNe CompareOp = "!="
+	CompareLt CompareOp = "<"
+	CompareLe CompareOp = "<="
+	CompareGt CompareOp = ">"
+	CompareGe CompareOp = ">="
+)
+
+// Comparison restricts a numeric column, e.g. exit_code != 0.
+type Comparison struct {
+	Op    CompareOp
+	Value int64
+}
+
+// Scope limits rows to Directory and everything below it, or to Directory
+// alone when Exact is set, and to SessionID and Hostname. Empty fields are
+// not filtered.
+type Scope struct {
+	Directory string
+	Exact     bool
+	SessionID string
+	Hostname  string
+}
+
+// CandidateFilter narrows the rows returned by FetchCandidates. Zero values
+// leave the corresponding field unfiltered; UntilMs is exclusive and
+// Directory also matches everything below it. Scope is applied on top of
+// Directory.
+type CandidateFilter struct {
+	FailFilter FailFilterMode
+	Directory  string
+	Scope      Scope
+	Hostname   string
+	SessionID  string
+	SinceMs    int64
+	UntilMs    int64
+	ExitCode   []Comparison
+	Duration   []Comparison
+}
+
+func (f CandidateFilter) where() (string, []any) {
+	w := whereBuilder{query: "", args: []any{}}
+
+	w.addFailFilter(f.FailFilter)
+
+	if f.Directory != "" {
+		w.addSubtree(f.Directory)
+	}
+
+	switch {
+	case f.Scope.Directory == "":
+	case f.Scope.Exact:
+		w.add("directory = ?", f.Scope.Directory)
+	default:
+		w.addSubtree(f.Scope.Directory)
+	}
+
+	if f.Scope.SessionID != "" {
+		w.add("session_id = ?", f.Scope.SessionID)
+	}
+
+	if f.Scope.Hostname != "" {
+		w.add("hostname = ?", f.Scope.Hostname)
+	}
+
+	if f.Hostname != "" {
+		w.add("hostname = ?", f.Hostname)
+	}
+
+	if f.SessionID != "" {
+		w.add("session_id = ?", f.SessionID)
+	}
+
+	if f.SinceMs > 0 {
+		w.add("ts_ms >= ?", f.SinceMs)
+	}
+
+	if f.UntilMs > 0 {
+		w.add("ts_ms < ?", f.UntilMs)
+	}
+
+	w.addComparisons("exit_code", f.ExitCode)
+	w.addComparisons("duration", f.Duration)
+
+	return w.query, w.args
+}
+
+// whereBuilder joins SQL conditions into a WHERE clause.
+type whereBuilder struct {
+	query string
+	args  []any
+}
+
+func (w *whereBuilder) add(clause string, values ...any) {
+	if w.query == "" {
+		w.query = " WHERE " + clause
+	} else {
+		w.query += " AND " + clause
+	}
+
+	w.args = append(w.args, values...)
+}
+
+// addSubtree matches dir and every directory below it.
+func (w *whereBuilder) addSubtree(dir string) {
+	prefix := strings.TrimRight(dir, string(filepath.Separator)) + string(filepath.Separator)
+	w.add("(directory = ? OR substr(directory, 1, length(?)) = ?)", dir, prefix, prefix)
+}
+
+func (w *whereBuilder) addFailFilter(mode FailFilterMode) {
+	switch mode {
+	case FailFilterInclude:
+		// No exit-code filter.
+	case FailFilterExclude:
+		w.add("exit_code = 0")
+	case FailFilterOnly:
+		w.add("exit_code != 0")
+	}
+}
+
+func (w *whereBuilder) addComparisons(column string, comparisons []Comparison) {
+	for _, c := range comparisons {
+		switch c.Op {
+		case CompareEq, CompareNe, CompareLt, CompareLe, CompareGt, CompareGe:
+			w.add(column+" "+string(c.Op)+" ?", c.Value)
+		}
+	}
+}
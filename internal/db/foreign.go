@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const (
+	nsPerMs           int64 = 1_000_000
+	msPerSecond       int64 = 1000
+	atuinUnknownValue       = -1
+)
+
+var (
+	atuinHistoryColumns = []string{
+		"timestamp", "duration", "exit", "command", "cwd", "session", "hostname", "deleted_at",
+	}
+	mcflyCommandColumns = []string{"id", "cmd", "session_id", "when_run", "exit_code", "dir"}
+)
+
+// ReadAtuinHistory reads the history table of an atuin database. Atuin stores
+// timestamps and durations in nanoseconds, uses -1 for unknown exit codes and
+// durations, and records the host as "hostname:username".
+func ReadAtuinHistory(source *sql.DB) ([]HistoryEntry, error) {
+	if err := requireTableColumns(source, "history", atuinHistoryColumns); err != nil {
+		return nil, fmt.Errorf("validating atuin schema: %w", err)
+	}
+
+	rows, err := source.QueryContext(
+		context.Background(),
+		`SELECT timestamp, duration, exit, command, cwd, session, hostname
+		 FROM history
+		 WHERE deleted_at IS NULL
+		 ORDER BY timestamp ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying atuin history: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var entries []HistoryEntry
+
+	for rows.Next() {
+		var (
+			timestampNs int64
+			durationNs  int64
+			exitCode    int
+			e           HistoryEntry
+		)
+
+		if err = rows.Scan(&timestampNs, &durationNs, &exitCode, &e.Command, &e.Directory, &e.SessionID, &e.Hostname); err != nil {
+			return nil, fmt.Errorf("scanning atuin history row: %w", err)
+		}
+
+		e.TsMs = timestampNs / nsPerMs
+		e.Duration = max(durationNs/nsPerMs, 0)
+
+		if exitCode != atuinUnknownValue {
+			e.ExitCode = exitCode
+		}
+
+		if host, _, found := strings.Cut(e.Hostname, ":"); found {
+			e.Hostname = host
+		}
+
+		e.RunCount = 1
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating atuin history rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ReadMcflyHistory reads the commands table of a McFly database. McFly stores
+// timestamps in seconds and does not record durations or hostnames.
+func ReadMcflyHistory(source *sql.DB) ([]HistoryEntry, error) {
+	if err := requireTableColumns(source, "commands", mcflyCommandColumns); err != nil {
+		return nil, fmt.Errorf("validating mcfly schema: %w", err)
+	}
+
+	rows, err := source.QueryContext(
+		context.Background(),
+		`SELECT when_run, exit_code, cmd, COALESCE(dir, ''), session_id
+		 FROM commands
+		 ORDER BY when_run ASC, id ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying mcfly history: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var entries []HistoryEntry
+
+	for rows.Next() {
+		var (
+			whenRun int64
+			e       HistoryEntry
+		)
+
+		if err = rows.Scan(&whenRun, &e.ExitCode, &e.Command, &e.Directory, &e.SessionID); err != nil {
+			return nil, fmt.Errorf("scanning mcfly history row: %w", err)
+		}
+
+		e.TsMs = whenRun * msPerSecond
+		e.RunCount = 1
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating mcfly history rows: %w", err)
+	}
+
+	return entries, nil
+}
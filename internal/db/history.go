@@ -15,6 +15,27 @@ type HistoryEntry struct {
 	Directory string
 	SessionID string
 	Hostname  string
+	RunCount  int
+	Shell     string
+	// FirstTsMs is the time of the first run folded into the entry. It
+	// identifies the entry for sync while TsMs moves to the latest run.
+	FirstTsMs int64 //nolint:staticcheck // matches TsMs
+}
+
+func (e HistoryEntry) runCount() int {
+	if e.RunCount < 1 {
+		return 1
+	}
+
+	return e.RunCount
+}
+
+func (e HistoryEntry) firstTsMs() int64 {
+	if e.FirstTsMs == 0 {
+		return e.TsMs
+	}
+
+	return e.FirstTsMs
 }
 
 type HistoryRepo struct {
@@ -26,12 +47,24 @@ func NewHistoryRepo(db *sql.DB) *HistoryRepo {
 }
 
 func (r *HistoryRepo) Insert(entry HistoryEntry) (int64, error) {
-	res, err := r.db.ExecContext(
+	return insertEntry(r.db, entry)
+}
+
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type sqlQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertEntry(exec sqlExecer, entry HistoryEntry) (int64, error) {
+	res, err := exec.ExecContext(
 		context.Background(),
-		`INSERT INTO history (ts_ms, duration, exit_code, command, directory, session_id, hostname)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO history (ts_ms, duration, exit_code, command, directory, session_id, hostname, run_count, shell, first_ts_ms)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		entry.TsMs, entry.Duration, entry.ExitCode, entry.Command,
-		entry.Directory, entry.SessionID, entry.Hostname,
+		entry.Directory, entry.SessionID, entry.Hostname, entry.runCount(), entry.Shell, entry.firstTsMs(),
 	)
 	if err != nil {
 		return 0, fmt.Errorf("inserting history entry: %w", err)
@@ -45,19 +78,55 @@ func (r *HistoryRepo) Insert(entry HistoryEntry) (int64, error) {
 	return id, nil
 }
 
+// Delete removes an entry and records a tombstone so the deletion reaches
+// other machines on the next sync.
 func (r *HistoryRepo) Delete(id int64) error {
-	_, err := r.db.ExecContext(context.Background(), `DELETE FROM history WHERE id = ?`, id)
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting delete transaction: %w", err)
+	}
+
+	committed := false
+
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+historySelectColumns+` FROM history WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("reading history entry %d: %w", id, err)
+	}
+
+	entries, err := scanEntries(rows)
+	_ = rows.Close()
+
 	if err != nil {
-		return fmt.Errorf("deleting history entry %d: %w", id, err)
+		return err
 	}
 
+	for _, e := range entries {
+		if err = deleteEntryTx(tx, e); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing delete transaction: %w", err)
+	}
+
+	committed = true
+
 	return nil
 }
 
 func (r *HistoryRepo) Recent(limit int) ([]HistoryEntry, error) {
 	rows, err := r.db.QueryContext(
 		context.Background(),
-		`SELECT id, ts_ms, duration, exit_code, command, directory, session_id, hostname
+		`SELECT `+historySelectColumns+`
 		 FROM history
 		 ORDER BY ts_ms DESC LIMIT ?`,
 		limit,
@@ -74,7 +143,7 @@ func (r *HistoryRepo) Recent(limit int) ([]HistoryEntry, error) {
 func (r *HistoryRepo) RecentInDir(dir string, limit int) ([]HistoryEntry, error) {
 	rows, err := r.db.QueryContext(
 		context.Background(),
-		`SELECT id, ts_ms, duration, exit_code, command, directory, session_id, hostname
+		`SELECT `+historySelectColumns+`
 		 FROM history WHERE directory = ?
 		 ORDER BY ts_ms DESC LIMIT ?`,
 		dir, limit,
@@ -89,9 +158,14 @@ func (r *HistoryRepo) RecentInDir(dir string, limit int) ([]HistoryEntry, error)
 }
 
 func (r *HistoryRepo) ListAll() ([]HistoryEntry, error) {
+	present, err := readHistoryColumns(r.db)
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err := r.db.QueryContext(
 		context.Background(),
-		`SELECT id, ts_ms, duration, exit_code, command, directory, session_id, hostname
+		`SELECT `+historySelectColumnsFor(present)+`
 		 FROM history
 		 ORDER BY ts_ms ASC, id ASC`,
 	)
@@ -104,21 +178,10 @@ func (r *HistoryRepo) ListAll() ([]HistoryEntry, error) {
 	return scanEntries(rows)
 }
 
-func (r *HistoryRepo) FetchCandidates(limit int, dedupe bool, failFilter FailFilterMode) ([]HistoryEntry, error) {
-	query := `SELECT id, ts_ms, duration, exit_code, command, directory, session_id, hostname
-		 FROM history`
-	args := []any{}
-
-	switch failFilter {
-	case FailFilterInclude:
-		// No exit-code filter.
-	case FailFilterExclude:
-		query += " WHERE exit_code = 0"
-	case FailFilterOnly:
-		query += " WHERE exit_code != 0"
-	}
-
-	query += " ORDER BY ts_ms DESC"
+func (r *HistoryRepo) FetchCandidates(limit int, dedupe bool, filter CandidateFilter) ([]HistoryEntry, error) {
+	where, args := filter.where()
+	query := `SELECT ` + historySelectColumns + `
+		 FROM history` + where + " ORDER BY ts_ms DESC"
 	if limit > 0 {
 		query += " LIMIT ?"
 
@@ -147,8 +210,8 @@ func (r *HistoryRepo) FetchCandidates(limit int, dedupe bool, failFilter FailFil
 func InsertIfNotExistsTx(tx *sql.Tx, entry HistoryEntry) (bool, error) {
 	res, err := tx.ExecContext(
 		context.Background(),
-		`INSERT INTO history (ts_ms, duration, exit_code, command, directory, session_id, hostname)
-		 SELECT ?, ?, ?, ?, ?, ?, ?
+		`INSERT INTO history (ts_ms, duration, exit_code, command, directory, session_id, hostname, run_count, shell, first_ts_ms)
+		 SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 		 WHERE NOT EXISTS (
 		   SELECT 1 FROM history
 		   WHERE ts_ms = ?
@@ -166,6 +229,9 @@ func InsertIfNotExistsTx(tx *sql.Tx, entry HistoryEntry) (bool, error) {
 		entry.Directory,
 		entry.SessionID,
 		entry.Hostname,
+		entry.runCount(),
+		entry.Shell,
+		entry.firstTsMs(),
 		entry.TsMs,
 		entry.Duration,
 		entry.ExitCode,
@@ -205,21 +271,12 @@ func dedupeEntries(entries []HistoryEntry) []HistoryEntry {
 func scanEntries(rows *sql.Rows) ([]HistoryEntry, error) {
 	var entries []HistoryEntry
 
-	for rows.Next() {
-		var e HistoryEntry
-
-		err := rows.Scan(&e.ID, &e.TsMs, &e.Duration, &e.ExitCode,
-			&e.Command, &e.Directory, &e.SessionID, &e.Hostname)
-		if err != nil {
-			return nil, fmt.Errorf("scanning history row: %w", err)
-		}
-
+	err := eachEntry(rows, func(e HistoryEntry) error {
 		entries = append(entries, e)
-	}
-
-	err := rows.Err()
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("iterating history rows: %w", err)
+		return nil, err
 	}
 
 	return entries, nil
@@ -18,18 +18,91 @@ CREATE TABLE IF NOT EXISTS history (
     command       TEXT    NOT NULL,
     directory     TEXT    NOT NULL DEFAULT '',
     session_id    TEXT    NOT NULL DEFAULT '',
-    hostname      TEXT    NOT NULL DEFAULT ''
+    hostname      TEXT    NOT NULL DEFAULT '',
+    run_count     INTEGER NOT NULL DEFAULT 1,
+    shell         TEXT    NOT NULL DEFAULT '',
+    first_ts_ms   INTEGER NOT NULL DEFAULT 0
 );
 
 CREATE INDEX IF NOT EXISTS idx_history_ts_ms         ON history(ts_ms);
 CREATE INDEX IF NOT EXISTS idx_history_directory      ON history(directory);
 CREATE INDEX IF NOT EXISTS idx_history_session_id     ON history(session_id);
+CREATE INDEX IF NOT EXISTS idx_history_session_ts     ON history(session_id, ts_ms, id);
 CREATE INDEX IF NOT EXISTS idx_history_command        ON history(command);
+
+CREATE TABLE IF NOT EXISTS sync_state (
+    name          TEXT    PRIMARY KEY,
+    position      INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS sync_merged (
+    history_id    INTEGER PRIMARY KEY
+);
+
+CREATE TABLE IF NOT EXISTS sync_updates (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    history_id    INTEGER NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    ts_ms         INTEGER NOT NULL,
+    command       TEXT    NOT NULL,
+    directory     TEXT    NOT NULL DEFAULT '',
+    session_id    TEXT    NOT NULL DEFAULT '',
+    hostname      TEXT    NOT NULL DEFAULT '',
+    remote        INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (ts_ms, command, directory, session_id, hostname)
+);
+
+CREATE TABLE IF NOT EXISTS selections (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    ts_ms         INTEGER NOT NULL,
+    query         TEXT    NOT NULL,
+    mode          TEXT    NOT NULL DEFAULT '',
+    history_id    INTEGER NOT NULL DEFAULT 0,
+    command       TEXT    NOT NULL,
+    position      INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_selections_query       ON selections(query);
 `
 
+const (
+	baseHistoryColumns   = "id, ts_ms, duration, exit_code, command, directory, session_id, hostname"
+	historySelectColumns = baseHistoryColumns + ", run_count, shell, first_ts_ms"
+)
+
+// historyIndexes covers migrated columns, so it runs after the migrations.
+const historyIndexes = `
+CREATE INDEX IF NOT EXISTS idx_history_first_ts_ms   ON history(first_ts_ms);
+`
+
+// historyColumnMigrations adds columns introduced after the initial schema to
+// databases created by older versions. The fallback expression is selected in
+// their place when reading read-only sources that predate them, and backfill,
+// when set, initializes the column for existing rows.
+var historyColumnMigrations = []struct {
+	name       string
+	definition string
+	fallback   string
+	backfill   string
+}{
+	{name: "run_count", definition: "INTEGER NOT NULL DEFAULT 1", fallback: "1", backfill: ""},
+	{name: "shell", definition: "TEXT NOT NULL DEFAULT ''", fallback: "''", backfill: ""},
+	{
+		name:       "first_ts_ms",
+		definition: "INTEGER NOT NULL DEFAULT 0",
+		fallback:   "ts_ms",
+		backfill:   "UPDATE history SET first_ts_ms = ts_ms",
+	},
+}
+
 var (
 	errHistoryTableMissing    = errors.New("history table is missing")
 	errHistoryColumnsMissing  = errors.New("history table is missing required columns")
+	errTableMissing           = errors.New("table is missing")
+	errTableColumnsMissing    = errors.New("table is missing required columns")
 	requiredHistoryColumnsSet = map[string]bool{
 		"id":         true,
 		"ts_ms":      true,
@@ -47,13 +120,91 @@ func ensureSchema(db *sql.DB) error {
 		return fmt.Errorf("applying schema: %w", err)
 	}
 
+	if err := migrateHistoryColumns(db); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(context.Background(), historyIndexes); err != nil {
+		return fmt.Errorf("creating history indexes: %w", err)
+	}
+
 	return nil
 }
 
+func migrateHistoryColumns(db *sql.DB) error {
+	present, err := readHistoryColumns(db)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range historyColumnMigrations {
+		if present[column.name] {
+			continue
+		}
+
+		statement := fmt.Sprintf("ALTER TABLE history ADD COLUMN %s %s", column.name, column.definition)
+		if _, err = db.ExecContext(context.Background(), statement); err != nil {
+			return fmt.Errorf("adding history column %q: %w", column.name, err)
+		}
+
+		if column.backfill == "" {
+			continue
+		}
+
+		if _, err = db.ExecContext(context.Background(), column.backfill); err != nil {
+			return fmt.Errorf("backfilling history column %q: %w", column.name, err)
+		}
+	}
+
+	return nil
+}
+
+func historySelectColumnsFor(present map[string]bool) string {
+	columns := baseHistoryColumns
+	for _, column := range historyColumnMigrations {
+		if present[column.name] {
+			columns += ", " + column.name
+		} else {
+			columns += ", " + column.fallback
+		}
+	}
+
+	return columns
+}
+
 func ValidateHistorySchema(db *sql.DB) error {
-	rows, err := db.QueryContext(context.Background(), `PRAGMA table_info(history)`)
+	present, err := readHistoryColumns(db)
+	if err != nil {
+		return err
+	}
+
+	if len(present) == 0 {
+		return errHistoryTableMissing
+	}
+
+	missing := make([]string, 0, len(requiredHistoryColumnsSet))
+	for col := range requiredHistoryColumnsSet {
+		if !present[col] {
+			missing = append(missing, col)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s", errHistoryColumnsMissing, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func readHistoryColumns(db *sql.DB) (map[string]bool, error) {
+	return readTableColumns(db, "history")
+}
+
+func readTableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.QueryContext(context.Background(), `SELECT name FROM pragma_table_info(?)`, table)
 	if err != nil {
-		return fmt.Errorf("reading history table info: %w", err)
+		return nil, fmt.Errorf("reading %s table info: %w", table, err)
 	}
 
 	defer func() { _ = rows.Close() }()
@@ -61,40 +212,41 @@ func ValidateHistorySchema(db *sql.DB) error {
 	present := map[string]bool{}
 
 	for rows.Next() {
-		var (
-			cid        int
-			name       string
-			columnType string
-			notNull    int
-			defaultVal sql.NullString
-			pk         int
-		)
-
-		if err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &pk); err != nil {
-			return fmt.Errorf("scanning history table info row: %w", err)
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning %s table info row: %w", table, err)
 		}
 
 		present[name] = true
 	}
 
 	if err = rows.Err(); err != nil {
-		return fmt.Errorf("iterating history table info rows: %w", err)
+		return nil, fmt.Errorf("iterating %s table info rows: %w", table, err)
+	}
+
+	return present, nil
+}
+
+func requireTableColumns(db *sql.DB, table string, columns []string) error {
+	present, err := readTableColumns(db, table)
+	if err != nil {
+		return err
 	}
 
 	if len(present) == 0 {
-		return errHistoryTableMissing
+		return fmt.Errorf("%w: %s", errTableMissing, table)
 	}
 
-	missing := make([]string, 0, len(requiredHistoryColumnsSet))
-	for col := range requiredHistoryColumnsSet {
+	var missing []string
+
+	for _, col := range columns {
 		if !present[col] {
 			missing = append(missing, col)
 		}
 	}
 
 	if len(missing) > 0 {
-		sort.Strings(missing)
-		return fmt.Errorf("%w: %s", errHistoryColumnsMissing, strings.Join(missing, ", "))
+		return fmt.Errorf("%w: %s: %s", errTableColumnsMissing, table, strings.Join(missing, ", "))
 	}
 
 	return nil
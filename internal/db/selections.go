@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Selection records which result the user accepted for a query. Position is
+// the 0-based row of the result in the list.
+type Selection struct {
+	TsMs      int64
+	Query     string
+	Mode      string
+	HistoryID int64
+	Command   string
+	Position  int
+}
+
+func (r *HistoryRepo) RecordSelection(s Selection) error {
+	_, err := r.db.ExecContext(
+		context.Background(),
+		`INSERT INTO selections (ts_ms, query, mode, history_id, command, position)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		s.TsMs, s.Query, s.Mode, s.HistoryID, s.Command, s.Position,
+	)
+	if err != nil {
+		return fmt.Errorf("recording selection: %w", err)
+	}
+
+	return nil
+}
+
+// SelectionCounts counts the commands chosen for queries similar to query:
+// the same query, or one that extends or is extended by it. An empty query
+// only matches selections made with an empty query.
+func (r *HistoryRepo) SelectionCounts(query string) (map[string]int, error) {
+	where, args := selectionQueryMatch(query)
+
+	rows, err := r.db.QueryContext(
+		context.Background(),
+		`SELECT command, COUNT(*) FROM selections
+		 WHERE `+where+`
+		 GROUP BY command`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying selections: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int)
+
+	for rows.Next() {
+		var (
+			command string
+			count   int
+		)
+
+		if err = rows.Scan(&command, &count); err != nil {
+			return nil, fmt.Errorf("scanning selections: %w", err)
+		}
+
+		counts[command] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating selections: %w", err)
+	}
+
+	return counts, nil
+}
+
+// selectionQueryMatch builds the condition for SelectionCounts so it can use
+// idx_selections_query: shorter stored queries are listed as the prefixes of
+// query, and longer ones fall in the byte range of strings starting with it.
+func selectionQueryMatch(query string) (string, []any) {
+	if query == "" {
+		return "query = ''", nil
+	}
+
+	var args []any
+
+	for i := range query {
+		if i > 0 {
+			args = append(args, query[:i])
+		}
+	}
+
+	args = append(args, query)
+
+	// UTF-8 never contains 0xFF, so bumping the last byte cannot overflow.
+	upper := []byte(query)
+	upper[len(upper)-1]++
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	args = append(args, query, string(upper))
+
+	return "(query IN (" + placeholders + ") OR (query >= ? AND query < ?))", args
+}
+
+// forgetSelectionsTx drops the selections of command once no history row
+// keeps it.
+func forgetSelectionsTx(tx *sql.Tx, command string) error {
+	_, err := tx.ExecContext(
+		context.Background(),
+		`DELETE FROM selections
+		 WHERE command = ? AND NOT EXISTS (SELECT 1 FROM history WHERE command = ?)`,
+		command, command,
+	)
+	if err != nil {
+		return fmt.Errorf("clearing selections of %q: %w", command, err)
+	}
+
+	return nil
+}
+
+// SelectionPositions returns how many selections were made at each
+// 0-based position.
+func (r *HistoryRepo) SelectionPositions() (map[int]int, error) {
+	rows, err := r.db.QueryContext(
+		context.Background(),
+		`SELECT position, COUNT(*) FROM selections GROUP BY position`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying selection positions: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	positions := make(map[int]int)
+
+	for rows.Next() {
+		var position, count int
+		if err = rows.Scan(&position, &count); err != nil {
+			return nil, fmt.Errorf("scanning selection positions: %w", err)
+		}
+
+		positions[position] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating selection positions: %w", err)
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// CommandStats summarizes every recorded run of one command.
+type CommandStats struct {
+	Runs      int64
+	Successes int64
+}
+
+// CommandStats returns run and success counts per command for the rows
+// matching filter. Collapsed rows count once per run.
+func (r *HistoryRepo) CommandStats(filter CandidateFilter) (map[string]CommandStats, error) {
+	where, args := filter.where()
+
+	rows, err := r.db.QueryContext(
+		context.Background(),
+		`SELECT command, SUM(run_count), SUM(CASE WHEN exit_code = 0 THEN run_count ELSE 0 END)
+		 FROM history`+where+`
+		 GROUP BY command`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying command stats: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	stats := make(map[string]CommandStats)
+
+	for rows.Next() {
+		var (
+			command string
+			s       CommandStats
+		)
+
+		if err = rows.Scan(&command, &s.Runs, &s.Successes); err != nil {
+			return nil, fmt.Errorf("scanning command stats: %w", err)
+		}
+
+		stats[command] = s
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating command stats: %w", err)
+	}
+
+	return stats, nil
+}
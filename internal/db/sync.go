@@ -0,0 +1,291 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Entries are identified across machines by when, where and on which host
+// they first ran, since row IDs are local to each database.
+const (
+	historyKeyMatch   = `first_ts_ms = ? AND command = ? AND directory = ? AND session_id = ? AND hostname = ?`
+	tombstoneKeyMatch = `ts_ms = ? AND command = ? AND directory = ? AND session_id = ? AND hostname = ?`
+)
+
+// SyncKey identifies a history entry across databases.
+type SyncKey struct {
+	TsMs      int64 //nolint:staticcheck // TsMs is clearer than TSMs
+	Command   string
+	Directory string
+	SessionID string
+	Hostname  string
+}
+
+func (e HistoryEntry) SyncKey() SyncKey {
+	return SyncKey{
+		TsMs:      e.firstTsMs(),
+		Command:   e.Command,
+		Directory: e.Directory,
+		SessionID: e.SessionID,
+		Hostname:  e.Hostname,
+	}
+}
+
+func (k SyncKey) args() []any {
+	return []any{k.TsMs, k.Command, k.Directory, k.SessionID, k.Hostname}
+}
+
+// LocalChanges are the inserts, updates and deletions made on this machine
+// after a set of sync cursors. Inserts hold new rows and rows updated in
+// place. Rows merged from other machines are excluded so they are never
+// echoed back.
+type LocalChanges struct {
+	Inserts         []HistoryEntry
+	Deletes         []SyncKey
+	HistoryCursor   int64
+	UpdateCursor    int64
+	TombstoneCursor int64
+}
+
+// SyncCursors are the positions in the local history, update and tombstone
+// sequences up to which changes have been delivered to a target.
+type SyncCursors struct {
+	History   int64
+	Update    int64
+	Tombstone int64
+}
+
+// ReadLocalChanges returns the local changes after cursors from a single
+// snapshot, along with the cursors to store once the changes have been
+// delivered.
+func ReadLocalChanges(database *sql.DB, cursors SyncCursors) (LocalChanges, error) {
+	ctx := context.Background()
+
+	tx, err := database.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault, ReadOnly: true})
+	if err != nil {
+		return LocalChanges{}, fmt.Errorf("starting sync read transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	changes := LocalChanges{
+		Inserts:         nil,
+		Deletes:         nil,
+		HistoryCursor:   cursors.History,
+		UpdateCursor:    cursors.Update,
+		TombstoneCursor: cursors.Tombstone,
+	}
+
+	for _, latest := range []struct {
+		table  string
+		cursor *int64
+	}{
+		{table: "history", cursor: &changes.HistoryCursor},
+		{table: "sync_updates", cursor: &changes.UpdateCursor},
+		{table: "sync_tombstones", cursor: &changes.TombstoneCursor},
+	} {
+		var id int64
+
+		err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM `+latest.table).Scan(&id)
+		if err != nil {
+			return LocalChanges{}, fmt.Errorf("reading latest %s ID: %w", latest.table, err)
+		}
+
+		*latest.cursor = max(*latest.cursor, id)
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT `+historySelectColumns+`
+		 FROM history
+		 WHERE (id > ? AND id <= ? AND id NOT IN (SELECT history_id FROM sync_merged))
+		    OR id IN (SELECT history_id FROM sync_updates WHERE id > ? AND id <= ?)
+		 ORDER BY id ASC`,
+		cursors.History, changes.HistoryCursor, cursors.Update, changes.UpdateCursor,
+	)
+	if err != nil {
+		return LocalChanges{}, fmt.Errorf("querying local history changes: %w", err)
+	}
+
+	changes.Inserts, err = scanEntries(rows)
+	_ = rows.Close()
+
+	if err != nil {
+		return LocalChanges{}, err
+	}
+
+	changes.Deletes, err = readLocalTombstones(tx, cursors.Tombstone, changes.TombstoneCursor)
+	if err != nil {
+		return LocalChanges{}, err
+	}
+
+	return changes, nil
+}
+
+func readLocalTombstones(tx *sql.Tx, after int64, upTo int64) ([]SyncKey, error) {
+	rows, err := tx.QueryContext(
+		context.Background(),
+		`SELECT ts_ms, command, directory, session_id, hostname
+		 FROM sync_tombstones
+		 WHERE id > ? AND id <= ? AND remote = 0
+		 ORDER BY id ASC`,
+		after, upTo,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying local deletions: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var keys []SyncKey
+
+	for rows.Next() {
+		var k SyncKey
+		if err = rows.Scan(&k.TsMs, &k.Command, &k.Directory, &k.SessionID, &k.Hostname); err != nil {
+			return nil, fmt.Errorf("scanning deletion row: %w", err)
+		}
+
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating deletion rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+// SyncPosition returns the stored sync cursor called name, or 0.
+func SyncPosition(q sqlQueryer, name string) (int64, error) {
+	var position int64
+
+	err := q.QueryRowContext(
+		context.Background(),
+		`SELECT position FROM sync_state WHERE name = ?`,
+		name,
+	).Scan(&position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("reading sync position %q: %w", name, err)
+	}
+
+	return position, nil
+}
+
+func SetSyncPosition(exec sqlExecer, name string, position int64) error {
+	_, err := exec.ExecContext(
+		context.Background(),
+		`INSERT INTO sync_state (name, position) VALUES (?, ?)
+		 ON CONFLICT (name) DO UPDATE SET position = excluded.position`,
+		name, position,
+	)
+	if err != nil {
+		return fmt.Errorf("storing sync position %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// ApplyRemoteInsertTx inserts an entry received from another machine unless
+// it was deleted, and reports whether it was inserted. An entry that is
+// already present takes the received timestamp, duration, exit code and run
+// count, which change when the sender folds repeated runs into it.
+func ApplyRemoteInsertTx(tx *sql.Tx, entry HistoryEntry) (bool, error) {
+	ctx := context.Background()
+	args := entry.SyncKey().args()
+
+	var deleted bool
+
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM sync_tombstones WHERE `+tombstoneKeyMatch+`)`, args...).Scan(&deleted)
+	if err != nil {
+		return false, fmt.Errorf("checking remote history entry: %w", err)
+	}
+
+	if deleted {
+		return false, nil
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE history SET ts_ms = ?, duration = ?, exit_code = ?, run_count = ? WHERE `+historyKeyMatch,
+		append([]any{entry.TsMs, entry.Duration, entry.ExitCode, entry.runCount()}, args...)...,
+	)
+	if err != nil {
+		return false, fmt.Errorf("updating remote history entry: %w", err)
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("reading affected rows for remote history entry: %w", err)
+	}
+
+	if updated > 0 {
+		return false, nil
+	}
+
+	id, err := insertEntry(tx, entry)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO sync_merged (history_id) VALUES (?)`, id)
+	if err != nil {
+		return false, fmt.Errorf("marking history entry %d as merged: %w", id, err)
+	}
+
+	return true, nil
+}
+
+// ApplyRemoteDeleteTx removes the entries matching a deletion received from
+// another machine and remembers the deletion so the entry is not merged again.
+// It returns the number of rows removed.
+func ApplyRemoteDeleteTx(tx *sql.Tx, key SyncKey) (int64, error) {
+	ctx := context.Background()
+	args := key.args()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT OR IGNORE INTO sync_tombstones (ts_ms, command, directory, session_id, hostname, remote)
+		 VALUES (?, ?, ?, ?, ?, 1)`,
+		args...,
+	); err != nil {
+		return 0, fmt.Errorf("recording remote deletion: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM sync_merged WHERE history_id IN (SELECT id FROM history WHERE `+historyKeyMatch+`)`,
+		args...,
+	); err != nil {
+		return 0, fmt.Errorf("clearing merged markers for remote deletion: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM sync_updates WHERE history_id IN (SELECT id FROM history WHERE `+historyKeyMatch+`)`,
+		args...,
+	); err != nil {
+		return 0, fmt.Errorf("clearing update markers for remote deletion: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM history WHERE `+historyKeyMatch, args...)
+	if err != nil {
+		return 0, fmt.Errorf("applying remote deletion: %w", err)
+	}
+
+	removed, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("reading affected rows for remote deletion: %w", err)
+	}
+
+	if err = forgetSelectionsTx(tx, key.Command); err != nil {
+		return 0, err
+	}
+
+	return removed, nil
+}
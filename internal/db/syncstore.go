@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+const syncStoreSchema = `
+CREATE TABLE IF NOT EXISTS sync_hosts (
+    host          TEXT    PRIMARY KEY,
+    token_hash    TEXT    NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS sync_changes (
+    seq           INTEGER PRIMARY KEY AUTOINCREMENT,
+    host          TEXT    NOT NULL,
+    payload       TEXT    NOT NULL
+);
+`
+
+// SyncStore is the database of a sync server. It keeps the opaque change
+// payloads uploaded by each host in arrival order and the hashes of the
+// hosts' access tokens.
+type SyncStore struct {
+	db *sql.DB
+}
+
+// StoredChange is a change payload with its position in the server log.
+type StoredChange struct {
+	Seq     int64
+	Host    string
+	Payload string
+}
+
+func OpenSyncStore(path string) (*SyncStore, error) {
+	database, err := openWithSchema(path, func(database *sql.DB) error {
+		if _, err := database.ExecContext(context.Background(), syncStoreSchema); err != nil {
+			return fmt.Errorf("applying sync store schema: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyncStore{db: database}, nil
+}
+
+func (s *SyncStore) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("closing sync store: %w", err)
+	}
+
+	return nil
+}
+
+// SetHostToken stores the token hash for host, replacing any previous one.
+func (s *SyncStore) SetHostToken(host string, tokenHash string) error {
+	_, err := s.db.ExecContext(
+		context.Background(),
+		`INSERT INTO sync_hosts (host, token_hash) VALUES (?, ?)
+		 ON CONFLICT (host) DO UPDATE SET token_hash = excluded.token_hash`,
+		host, tokenHash,
+	)
+	if err != nil {
+		return fmt.Errorf("storing token for host %q: %w", host, err)
+	}
+
+	return nil
+}
+
+// HostForToken returns the host owning tokenHash.
+func (s *SyncStore) HostForToken(tokenHash string) (string, bool, error) {
+	var host string
+
+	err := s.db.QueryRowContext(
+		context.Background(),
+		`SELECT host FROM sync_hosts WHERE token_hash = ?`,
+		tokenHash,
+	).Scan(&host)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, fmt.Errorf("looking up sync token: %w", err)
+	}
+
+	return host, true, nil
+}
+
+// Append stores payloads uploaded by host.
+func (s *SyncStore) Append(host string, payloads []string) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting sync append transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	for _, payload := range payloads {
+		if _, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO sync_changes (host, payload) VALUES (?, ?)`,
+			host, payload,
+		); err != nil {
+			return fmt.Errorf("storing change from host %q: %w", host, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing sync append: %w", err)
+	}
+
+	return nil
+}
+
+// ChangesAfter returns up to limit changes after seq that were uploaded by
+// hosts other than excludeHost.
+func (s *SyncStore) ChangesAfter(seq int64, excludeHost string, limit int) ([]StoredChange, error) {
+	rows, err := s.db.QueryContext(
+		context.Background(),
+		`SELECT seq, host, payload FROM sync_changes
+		 WHERE seq > ? AND host != ?
+		 ORDER BY seq ASC LIMIT ?`,
+		seq, excludeHost, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying sync changes: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var changes []StoredChange
+
+	for rows.Next() {
+		var c StoredChange
+		if err = rows.Scan(&c.Seq, &c.Host, &c.Payload); err != nil {
+			return nil, fmt.Errorf("scanning sync change: %w", err)
+		}
+
+		changes = append(changes, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating sync changes: %w", err)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// LastSessionCommand returns the most recent command recorded in a shell
+// session.
+func (r *HistoryRepo) LastSessionCommand(sessionID string) (string, bool, error) {
+	var command string
+
+	err := r.db.QueryRowContext(
+		context.Background(),
+		`SELECT command FROM history
+		 WHERE session_id = ?
+		 ORDER BY ts_ms DESC, id DESC
+		 LIMIT 1`,
+		sessionID,
+	).Scan(&command)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, fmt.Errorf("querying last command of session: %w", err)
+	}
+
+	return command, true, nil
+}
+
+// transitionsQuery finds the command after each run of prev. The lookup of
+// the next row walks idx_history_session_ts instead of sorting the session.
+const transitionsQuery = `SELECT n.command, COUNT(*)
+	FROM history p
+	JOIN history n ON n.id = (
+	  SELECT id FROM history
+	  WHERE session_id = p.session_id
+	    AND (ts_ms, id) > (p.ts_ms, p.id)
+	  ORDER BY session_id, ts_ms, id
+	  LIMIT 1
+	)
+	WHERE p.command = ? AND p.session_id != ''
+	GROUP BY n.command`
+
+// Transitions counts which commands were run next, in the same session,
+// after each earlier run of prev.
+func (r *HistoryRepo) Transitions(prev string) (map[string]int, error) {
+	rows, err := r.db.QueryContext(context.Background(), transitionsQuery, prev)
+	if err != nil {
+		return nil, fmt.Errorf("querying command transitions: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int)
+
+	for rows.Next() {
+		var (
+			command string
+			count   int
+		)
+
+		if err = rows.Scan(&command, &count); err != nil {
+			return nil, fmt.Errorf("scanning command transitions: %w", err)
+		}
+
+		counts[command] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating command transitions: %w", err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,102 @@
+package histfile
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+// ReadBash parses a bash history file. When the file contains HISTTIMEFORMAT
+// "#<epoch>" comment lines, every line up to the next timestamp belongs to
+// the same entry, which is how bash stores multiline commands. Lines before
+// the first timestamp, or in files without any, are one command each and get
+// synthetic timestamps as in ReadPSReadLine, ending at endMs.
+func ReadBash(r io.Reader, endMs int64) ([]db.HistoryEntry, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries     []db.HistoryEntry
+		current     []string
+		tsMs        int64
+		timestamped bool
+	)
+
+	flush := func() {
+		command := strings.Join(current, "\n")
+		if !isBlank(command) {
+			entries = append(entries, newEntry(tsMs, command))
+		}
+
+		current = current[:0]
+	}
+
+	for _, line := range lines {
+		if ts, ok := parseBashTimestamp(string(line)); ok {
+			flush()
+
+			tsMs, timestamped = ts, true
+
+			continue
+		}
+
+		if !timestamped {
+			if command := string(line); !isBlank(command) {
+				entries = append(entries, newEntry(0, command))
+			}
+
+			continue
+		}
+
+		current = append(current, string(line))
+	}
+
+	flush()
+	synthesizeTimestamps(entries, endMs)
+
+	return entries, nil
+}
+
+func parseBashTimestamp(line string) (int64, bool) {
+	digits, ok := strings.CutPrefix(line, "#")
+	if !ok || digits == "" {
+		return 0, false
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+
+	sec, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return sec * msPerSecond, true
+}
+
+// BashWriter writes entries as a bash history file with "#<epoch>" timestamp
+// lines, so multiline commands read back as single entries.
+type BashWriter struct {
+	w io.Writer
+}
+
+func NewBashWriter(w io.Writer) *BashWriter {
+	return &BashWriter{w: w}
+}
+
+func (b *BashWriter) Write(e db.HistoryEntry) error {
+	_, err := fmt.Fprintf(b.w, "#%d\n%s\n", e.TsMs/msPerSecond, e.Command)
+	if err != nil {
+		return fmt.Errorf("writing bash history entry: %w", err)
+	}
+
+	return nil
+}
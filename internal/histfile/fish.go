@@ -0,0 +1,138 @@
+package histfile
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+const (
+	fishCmdPrefix   = "- cmd: "
+	fishWhenPrefix  = "  when: "
+	fishPathsHeader = "  paths:"
+	fishPathPrefix  = "    - "
+)
+
+// FishEntry is a fish history item together with the path arguments fish
+// recorded for it.
+type FishEntry struct {
+	Entry db.HistoryEntry
+	Paths []string
+}
+
+// ReadFish parses fish's YAML-like history file. Values use fish's escaping,
+// where "\\" is a backslash and "\n" is a newline.
+func ReadFish(r io.Reader) ([]FishEntry, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries []FishEntry
+		current *FishEntry
+		inPaths bool
+	)
+
+	flush := func() {
+		if current != nil && !isBlank(current.Entry.Command) {
+			entries = append(entries, *current)
+		}
+
+		current = nil
+	}
+
+	for _, raw := range lines {
+		line := string(raw)
+
+		if command, ok := strings.CutPrefix(line, fishCmdPrefix); ok {
+			flush()
+
+			current = &FishEntry{
+				Entry: newEntry(0, unescapeFish(command)),
+				Paths: []string{},
+			}
+			inPaths = false
+
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, fishWhenPrefix):
+			inPaths = false
+
+			if sec, parseErr := strconv.ParseInt(strings.TrimSpace(line[len(fishWhenPrefix):]), 10, 64); parseErr == nil {
+				current.Entry.TsMs = sec * msPerSecond
+			}
+		case line == fishPathsHeader:
+			inPaths = true
+		case inPaths && strings.HasPrefix(line, fishPathPrefix):
+			current.Paths = append(current.Paths, unescapeFish(line[len(fishPathPrefix):]))
+		default:
+			inPaths = false
+		}
+	}
+
+	flush()
+
+	return entries, nil
+}
+
+func unescapeFish(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case '\\':
+			b.WriteByte('\\')
+			i++
+		case 'n':
+			b.WriteByte('\n')
+			i++
+		default:
+			b.WriteByte('\\')
+		}
+	}
+
+	return b.String()
+}
+
+// FishWriter writes entries in fish's history file format.
+type FishWriter struct {
+	w io.Writer
+}
+
+func NewFishWriter(w io.Writer) *FishWriter {
+	return &FishWriter{w: w}
+}
+
+func (f *FishWriter) Write(e db.HistoryEntry) error {
+	_, err := fmt.Fprintf(f.w, "%s%s\n%s%d\n", fishCmdPrefix, escapeFish(e.Command), fishWhenPrefix, e.TsMs/msPerSecond)
+	if err != nil {
+		return fmt.Errorf("writing fish history entry: %w", err)
+	}
+
+	return nil
+}
+
+func escapeFish(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,87 @@
+package histfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+const msPerSecond int64 = 1000
+
+// readLines returns the raw lines of r without their trailing line endings.
+// Unlike bufio.Scanner it has no upper bound on line length.
+func readLines(r io.Reader) ([][]byte, error) {
+	var lines [][]byte
+
+	err := eachLine(r, func(line []byte) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// eachLine calls fn with each line of r as it is read, without its trailing
+// line ending. The line is only valid until fn returns, and errors from fn are
+// returned unchanged.
+func eachLine(r io.Reader, fn func(line []byte) error) error {
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if fnErr := fn(trimLineEnding(line)); fnErr != nil {
+				return fnErr
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("reading history file: %w", err)
+		}
+	}
+}
+
+func trimLineEnding(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+
+	return line
+}
+
+func isBlank(command string) bool {
+	return strings.TrimSpace(command) == ""
+}
+
+// newEntry returns a single-run history entry with only the fields every
+// history file format provides.
+func newEntry(tsMs int64, command string) db.HistoryEntry {
+	return db.HistoryEntry{
+		ID:        0,
+		TsMs:      tsMs,
+		Duration:  0,
+		ExitCode:  0,
+		Command:   command,
+		Directory: "",
+		SessionID: "",
+		Hostname:  "",
+		RunCount:  1,
+		Shell:     "",
+		FirstTsMs: 0,
+	}
+}
@@ -0,0 +1,360 @@
+package histfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+func TestReadBashPlain(t *testing.T) {
+	entries, err := ReadBash(strings.NewReader("ls -la\n\ngit status\r\nls -la\n"), 5000)
+	if err != nil {
+		t.Fatalf("ReadBash() error: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("ReadBash() returned %d entries, want 3", len(entries))
+	}
+
+	if entries[1].Command != "git status" {
+		t.Fatalf("entries[1].Command = %q, want %q", entries[1].Command, "git status")
+	}
+
+	for i, want := range []int64{4998, 4999, 5000} {
+		if entries[i].TsMs != want {
+			t.Fatalf("entries[%d].TsMs = %d, want %d", i, entries[i].TsMs, want)
+		}
+	}
+}
+
+func TestReadBashTimestampsAndMultiline(t *testing.T) {
+	input := "#1700000000\necho one\n#1700000005\nfor i in 1 2; do\n  echo $i\ndone\n#1700000010\n#not-a-ts\n"
+
+	entries, err := ReadBash(strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatalf("ReadBash() error: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("ReadBash() returned %d entries, want 3: %+v", len(entries), entries)
+	}
+
+	if entries[0].TsMs != 1_700_000_000_000 || entries[0].Command != "echo one" {
+		t.Fatalf("entries[0] = %+v", entries[0])
+	}
+
+	wantMultiline := "for i in 1 2; do\n  echo $i\ndone"
+	if entries[1].TsMs != 1_700_000_005_000 || entries[1].Command != wantMultiline {
+		t.Fatalf("entries[1] = %+v, want multiline command %q", entries[1], wantMultiline)
+	}
+
+	if entries[2].Command != "#not-a-ts" {
+		t.Fatalf("entries[2].Command = %q, want %q", entries[2].Command, "#not-a-ts")
+	}
+}
+
+func TestReadBashDatesLinesBeforeFirstTimestamp(t *testing.T) {
+	input := "ls\nls\n#1700000000\necho one\n"
+
+	entries, err := ReadBash(strings.NewReader(input), 1_700_000_100_000)
+	if err != nil {
+		t.Fatalf("ReadBash() error: %v", err)
+	}
+
+	want := []int64{1_699_999_999_998, 1_699_999_999_999, 1_700_000_000_000}
+	if len(entries) != len(want) {
+		t.Fatalf("ReadBash() returned %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+
+	for i, ts := range want {
+		if entries[i].TsMs != ts {
+			t.Fatalf("entries[%d].TsMs = %d, want %d", i, entries[i].TsMs, ts)
+		}
+	}
+}
+
+func TestReadZshPlainGetsSyntheticTimestamps(t *testing.T) {
+	input := ": 1700000000:0;echo one\nls\nls\n"
+
+	entries, err := ReadZsh(strings.NewReader(input), 1_700_000_100_000)
+	if err != nil {
+		t.Fatalf("ReadZsh() error: %v", err)
+	}
+
+	want := []int64{1_700_000_000_000, 1_700_000_099_999, 1_700_000_100_000}
+	if len(entries) != len(want) {
+		t.Fatalf("ReadZsh() returned %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+
+	for i, ts := range want {
+		if entries[i].TsMs != ts {
+			t.Fatalf("entries[%d].TsMs = %d, want %d", i, entries[i].TsMs, ts)
+		}
+	}
+}
+
+func TestReadZshExtendedFormat(t *testing.T) {
+	input := ": 1700000000:3;make build\n: 1700000010:0;echo first\\\necho second\nplain command\n"
+
+	entries, err := ReadZsh(strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatalf("ReadZsh() error: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("ReadZsh() returned %d entries, want 3: %+v", len(entries), entries)
+	}
+
+	if entries[0].TsMs != 1_700_000_000_000 || entries[0].Duration != 3000 || entries[0].Command != "make build" {
+		t.Fatalf("entries[0] = %+v", entries[0])
+	}
+
+	if entries[1].Command != "echo first\necho second" {
+		t.Fatalf("entries[1].Command = %q, want multiline command", entries[1].Command)
+	}
+
+	if entries[2].Command != "plain command" || entries[2].TsMs != 0 {
+		t.Fatalf("entries[2] = %+v", entries[2])
+	}
+}
+
+func TestReadZshUnmetafiesBytes(t *testing.T) {
+	// The em dash is 0xE2 0x80 0x94; zsh stores the last byte metafied.
+	raw := []byte(": 1700000000:0;echo ")
+	raw = append(raw, 0xE2, 0x80, zshMeta, 0x94^zshMetaMask, '\n')
+
+	entries, err := ReadZsh(strings.NewReader(string(raw)), 0)
+	if err != nil {
+		t.Fatalf("ReadZsh() error: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("ReadZsh() returned %d entries, want 1", len(entries))
+	}
+
+	if want := "echo —"; entries[0].Command != want {
+		t.Fatalf("entries[0].Command = %q, want %q", entries[0].Command, want)
+	}
+}
+
+func TestReadFish(t *testing.T) {
+	input := "- cmd: echo one\\ntwo \\\\ end\n" +
+		"  when: 1700000000\n" +
+		"  paths:\n" +
+		"    - ~/notes.txt\n" +
+		"    - ./src\n" +
+		"- cmd: ls\n" +
+		"  when: 1700000010\n"
+
+	entries, err := ReadFish(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadFish() error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("ReadFish() returned %d entries, want 2", len(entries))
+	}
+
+	if want := "echo one\ntwo \\ end"; entries[0].Entry.Command != want {
+		t.Fatalf("entries[0].Command = %q, want %q", entries[0].Entry.Command, want)
+	}
+
+	if entries[0].Entry.TsMs != 1_700_000_000_000 {
+		t.Fatalf("entries[0].TsMs = %d, want 1700000000000", entries[0].Entry.TsMs)
+	}
+
+	if len(entries[0].Paths) != 2 || entries[0].Paths[1] != "./src" {
+		t.Fatalf("entries[0].Paths = %v, want [~/notes.txt ./src]", entries[0].Paths)
+	}
+
+	if len(entries[1].Paths) != 0 || entries[1].Entry.TsMs != 1_700_000_010_000 {
+		t.Fatalf("entries[1] = %+v", entries[1])
+	}
+}
+
+func TestReadPSReadLineJoinsContinuationsAndSynthesizesTimestamps(t *testing.T) {
+	input := "Get-ChildItem\r\nGet-Process |`\r\n  Where-Object CPU\r\n\r\nexit\r\n"
+
+	entries, err := ReadPSReadLine(strings.NewReader(input), 5000)
+	if err != nil {
+		t.Fatalf("ReadPSReadLine() error: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("ReadPSReadLine() returned %d entries, want 3: %+v", len(entries), entries)
+	}
+
+	wantMultiline := "Get-Process |\n  Where-Object CPU"
+	if entries[1].Command != wantMultiline {
+		t.Fatalf("entries[1].Command = %q, want %q", entries[1].Command, wantMultiline)
+	}
+
+	for i, want := range []int64{4998, 4999, 5000} {
+		if entries[i].TsMs != want {
+			t.Fatalf("entries[%d].TsMs = %d, want %d", i, entries[i].TsMs, want)
+		}
+	}
+}
+
+func TestJSONLRoundTrip(t *testing.T) {
+	want := []db.HistoryEntry{
+		{TsMs: 1000, Duration: 5, ExitCode: 1, Command: "echo <a> & b", Directory: "/tmp", SessionID: "s1", Hostname: "h", RunCount: 2, Shell: "zsh"},
+		{TsMs: 2000, Command: "for i in 1 2\ndo echo \"$i\"\ndone", RunCount: 1},
+	}
+
+	var buf bytes.Buffer
+
+	writer := NewJSONLWriter(&buf)
+	for _, entry := range want {
+		if err := writer.Write(entry); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != len(want) {
+		t.Fatalf("wrote %d lines, want %d:\n%s", lines, len(want), buf.String())
+	}
+
+	got, err := ReadJSONL(&buf)
+	if err != nil {
+		t.Fatalf("ReadJSONL() error: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadJSONL() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadJSONLRejectsUnknownVersion(t *testing.T) {
+	_, err := ReadJSONL(strings.NewReader(`{"v":99,"command":"ls"}` + "\n"))
+	if !errors.Is(err, errJSONLVersion) {
+		t.Fatalf("ReadJSONL() error = %v, want %v", err, errJSONLVersion)
+	}
+}
+
+func TestEachJSONLDeliversEntriesAsTheyAreRead(t *testing.T) {
+	errPipe := errors.New("pipe closed")
+	r := io.MultiReader(
+		strings.NewReader(`{"v":1,"ts_ms":1000,"command":"ls"}`+"\n\n"),
+		iotest.ErrReader(errPipe),
+	)
+
+	var got []string
+
+	err := EachJSONL(r, func(entry db.HistoryEntry) error {
+		got = append(got, entry.Command)
+		return nil
+	})
+	if !errors.Is(err, errPipe) {
+		t.Fatalf("EachJSONL() error = %v, want %v", err, errPipe)
+	}
+
+	if !reflect.DeepEqual(got, []string{"ls"}) {
+		t.Fatalf("EachJSONL() delivered %q before the read error, want [ls]", got)
+	}
+
+	_, err = ReadJSONL(strings.NewReader(`{"v":1,"command":"ls"}` + "\n\n{\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("ReadJSONL() error = %v, want a parse error on line 3", err)
+	}
+}
+
+func TestNativeWritersRoundTripMultilineCommands(t *testing.T) {
+	entries := []db.HistoryEntry{
+		{TsMs: 1_700_000_000_000, Duration: 2000, Command: "echo one"},
+		{TsMs: 1_700_000_005_000, Command: "for i in 1 2; do\n  echo \"$i\" \\\\ — done\ndone"},
+		{TsMs: 1_700_000_009_000, Command: "Get-Item `\nfoo"},
+	}
+
+	tests := []struct {
+		name  string
+		write func(*bytes.Buffer) func(db.HistoryEntry) error
+		read  func(*bytes.Buffer) ([]db.HistoryEntry, error)
+		times bool
+	}{
+		{
+			name:  "bash",
+			write: func(b *bytes.Buffer) func(db.HistoryEntry) error { return NewBashWriter(b).Write },
+			read:  func(b *bytes.Buffer) ([]db.HistoryEntry, error) { return ReadBash(b, 0) },
+			times: true,
+		},
+		{
+			name:  "zsh",
+			write: func(b *bytes.Buffer) func(db.HistoryEntry) error { return NewZshWriter(b).Write },
+			read:  func(b *bytes.Buffer) ([]db.HistoryEntry, error) { return ReadZsh(b, 0) },
+			times: true,
+		},
+		{
+			name:  "fish",
+			write: func(b *bytes.Buffer) func(db.HistoryEntry) error { return NewFishWriter(b).Write },
+			read: func(b *bytes.Buffer) ([]db.HistoryEntry, error) {
+				fishEntries, err := ReadFish(b)
+				got := make([]db.HistoryEntry, len(fishEntries))
+
+				for i, e := range fishEntries {
+					got[i] = e.Entry
+				}
+
+				return got, err
+			},
+			times: true,
+		},
+		{
+			name:  "psreadline",
+			write: func(b *bytes.Buffer) func(db.HistoryEntry) error { return NewPSReadLineWriter(b).Write },
+			read:  func(b *bytes.Buffer) ([]db.HistoryEntry, error) { return ReadPSReadLine(b, 0) },
+			times: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			write := tc.write(&buf)
+			for _, entry := range entries {
+				if err := write(entry); err != nil {
+					t.Fatalf("Write() error: %v", err)
+				}
+			}
+
+			got, err := tc.read(&buf)
+			if err != nil {
+				t.Fatalf("read error: %v", err)
+			}
+
+			if len(got) != len(entries) {
+				t.Fatalf("read %d entries, want %d: %+v", len(got), len(entries), got)
+			}
+
+			for i, want := range entries {
+				if got[i].Command != want.Command {
+					t.Fatalf("entries[%d].Command = %q, want %q", i, got[i].Command, want.Command)
+				}
+
+				if tc.times && got[i].TsMs != want.TsMs {
+					t.Fatalf("entries[%d].TsMs = %d, want %d", i, got[i].TsMs, want.TsMs)
+				}
+			}
+		})
+	}
+}
+
+func TestZshWriterMetafiesSpecialBytes(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := NewZshWriter(&buf).Write(db.HistoryEntry{TsMs: 1_700_000_000_000, Command: "echo —"}); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	want := []byte(": 1700000000:0;echo \xe2\x80\x83\xb4\n")
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("Write() = %q, want %q", buf.Bytes(), want)
+	}
+}
@@ -0,0 +1,116 @@
+package histfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+// JSONLVersion is the interchange format version written by WriteJSONL.
+// Readers reject records from newer versions rather than dropping fields.
+const JSONLVersion = 1
+
+var errJSONLVersion = errors.New("unsupported JSONL version")
+
+// jsonlRecord is one line of the JSONL interchange format. Row IDs are local
+// to a database and are not exported.
+type jsonlRecord struct {
+	Version   int    `json:"v"`
+	TsMs      int64  `json:"ts_ms"` //nolint:tagliatelle // matches the database column
+	Duration  int64  `json:"duration"`
+	ExitCode  int    `json:"exit_code"` //nolint:tagliatelle // matches the database column
+	Command   string `json:"command"`
+	Directory string `json:"directory"`
+	SessionID string `json:"session_id"` //nolint:tagliatelle // matches the database column
+	Hostname  string `json:"hostname"`
+	RunCount  int    `json:"run_count"` //nolint:tagliatelle // matches the database column
+	Shell     string `json:"shell"`
+}
+
+// JSONLWriter writes history entries as one JSON object per line.
+type JSONLWriter struct {
+	enc *json.Encoder
+}
+
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return &JSONLWriter{enc: enc}
+}
+
+func (w *JSONLWriter) Write(e db.HistoryEntry) error {
+	err := w.enc.Encode(jsonlRecord{
+		Version:   JSONLVersion,
+		TsMs:      e.TsMs,
+		Duration:  e.Duration,
+		ExitCode:  e.ExitCode,
+		Command:   e.Command,
+		Directory: e.Directory,
+		SessionID: e.SessionID,
+		Hostname:  e.Hostname,
+		RunCount:  e.RunCount,
+		Shell:     e.Shell,
+	})
+	if err != nil {
+		return fmt.Errorf("writing JSONL record: %w", err)
+	}
+
+	return nil
+}
+
+// ReadJSONL parses history written by JSONLWriter. Blank lines are ignored.
+func ReadJSONL(r io.Reader) ([]db.HistoryEntry, error) {
+	var entries []db.HistoryEntry
+
+	err := EachJSONL(r, func(entry db.HistoryEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// EachJSONL parses history written by JSONLWriter one line at a time and calls
+// fn with each entry, so the input never has to fit in memory. Blank lines are
+// ignored and errors from fn are returned unchanged.
+func EachJSONL(r io.Reader, fn func(db.HistoryEntry) error) error {
+	lineNumber := 0
+
+	return eachLine(r, func(line []byte) error {
+		lineNumber++
+
+		if isBlank(string(line)) {
+			return nil
+		}
+
+		var record jsonlRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("parsing JSONL line %d: %w", lineNumber, err)
+		}
+
+		if record.Version < 1 || record.Version > JSONLVersion {
+			return fmt.Errorf("%w %d on line %d", errJSONLVersion, record.Version, lineNumber)
+		}
+
+		return fn(db.HistoryEntry{
+			ID:        0,
+			TsMs:      record.TsMs,
+			Duration:  record.Duration,
+			ExitCode:  record.ExitCode,
+			Command:   record.Command,
+			Directory: record.Directory,
+			SessionID: record.SessionID,
+			Hostname:  record.Hostname,
+			RunCount:  record.RunCount,
+			Shell:     record.Shell,
+			FirstTsMs: 0,
+		})
+	})
+}
@@ -0,0 +1,81 @@
+package histfile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+// ReadPSReadLine parses a PSReadLine ConsoleHost_history.txt file. A line
+// ending in a backtick continues on the next line, which is how PSReadLine
+// stores multiline commands. The file has no timestamps, so entries get
+// synthetic ones one millisecond apart with the last entry at endMs.
+func ReadPSReadLine(r io.Reader, endMs int64) ([]db.HistoryEntry, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries []db.HistoryEntry
+		current []string
+	)
+
+	flush := func() {
+		command := strings.Join(current, "\n")
+		if !isBlank(command) {
+			entries = append(entries, newEntry(0, command))
+		}
+
+		current = current[:0]
+	}
+
+	for _, line := range lines {
+		text, continued := strings.CutSuffix(string(line), "`")
+		current = append(current, text)
+
+		if !continued {
+			flush()
+		}
+	}
+
+	flush()
+	synthesizeTimestamps(entries, endMs)
+
+	return entries, nil
+}
+
+// synthesizeTimestamps dates entries without a timestamp (TsMs 0) one
+// millisecond before the next entry in file order, or before endMs for the
+// ones after the last timestamped entry, so the last undated entry lands on
+// endMs.
+func synthesizeTimestamps(entries []db.HistoryEntry, endMs int64) {
+	next := endMs + 1
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].TsMs == 0 {
+			entries[i].TsMs = next - 1
+		}
+
+		next = entries[i].TsMs
+	}
+}
+
+// PSReadLineWriter writes entries as a PSReadLine history file, continuing
+// multiline commands with a trailing backtick. Timestamps are not stored.
+type PSReadLineWriter struct {
+	w io.Writer
+}
+
+func NewPSReadLineWriter(w io.Writer) *PSReadLineWriter {
+	return &PSReadLineWriter{w: w}
+}
+
+func (p *PSReadLineWriter) Write(e db.HistoryEntry) error {
+	if _, err := io.WriteString(p.w, strings.ReplaceAll(e.Command, "\n", "`\n")+"\n"); err != nil {
+		return fmt.Errorf("writing PSReadLine history entry: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package histfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+const (
+	zshMeta     byte = 0x83
+	zshMarker   byte = 0xa2
+	zshMetaMask byte = 0x20
+)
+
+// ReadZsh parses a zsh history file in either plain or EXTENDED_HISTORY
+// (": <start>:<elapsed>;<command>") format. Lines ending in a backslash
+// continue the entry on the next line, and metafied bytes are decoded.
+// Plain entries get synthetic timestamps as in ReadBash, ending at endMs.
+func ReadZsh(r io.Reader, endMs int64) ([]db.HistoryEntry, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []db.HistoryEntry
+
+	for i := 0; i < len(lines); i++ {
+		line := string(unmetafy(lines[i]))
+
+		for strings.HasSuffix(line, `\`) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + "\n" + string(unmetafy(lines[i]))
+		}
+
+		entry := parseZshLine(line)
+		if isBlank(entry.Command) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	synthesizeTimestamps(entries, endMs)
+
+	return entries, nil
+}
+
+func parseZshLine(line string) db.HistoryEntry {
+	rest, ok := strings.CutPrefix(line, ": ")
+	if !ok {
+		return newEntry(0, line)
+	}
+
+	header, command, ok := strings.Cut(rest, ";")
+	if !ok {
+		return newEntry(0, line)
+	}
+
+	startStr, elapsedStr, ok := strings.Cut(header, ":")
+	if !ok {
+		return newEntry(0, line)
+	}
+
+	start, err := strconv.ParseInt(strings.TrimSpace(startStr), 10, 64)
+	if err != nil {
+		return newEntry(0, line)
+	}
+
+	elapsed, err := strconv.ParseInt(strings.TrimSpace(elapsedStr), 10, 64)
+	if err != nil {
+		elapsed = 0
+	}
+
+	entry := newEntry(start*msPerSecond, command)
+	entry.Duration = elapsed * msPerSecond
+
+	return entry
+}
+
+// unmetafy reverses zsh's metafication, where bytes that are special to the
+// shell are stored as the Meta byte followed by the original byte XOR 0x20.
+func unmetafy(line []byte) []byte {
+	if bytes.IndexByte(line, zshMeta) < 0 {
+		return line
+	}
+
+	out := make([]byte, 0, len(line))
+	for i := 0; i < len(line); i++ {
+		if line[i] == zshMeta && i+1 < len(line) {
+			i++
+			out = append(out, line[i]^zshMetaMask)
+
+			continue
+		}
+
+		out = append(out, line[i])
+	}
+
+	return out
+}
+
+// ZshWriter writes entries in EXTENDED_HISTORY format. Newlines inside a
+// command are written as backslash continuations and special bytes are
+// metafied, as zsh itself does.
+type ZshWriter struct {
+	w io.Writer
+}
+
+func NewZshWriter(w io.Writer) *ZshWriter {
+	return &ZshWriter{w: w}
+}
+
+func (z *ZshWriter) Write(e db.HistoryEntry) error {
+	command := strings.ReplaceAll(e.Command, "\n", "\\\n")
+	header := fmt.Sprintf(": %d:%d;", e.TsMs/msPerSecond, e.Duration/msPerSecond)
+
+	line := append([]byte(header), metafy([]byte(command))...)
+	line = append(line, '\n')
+
+	if _, err := z.w.Write(line); err != nil {
+		return fmt.Errorf("writing zsh history entry: %w", err)
+	}
+
+	return nil
+}
+
+// metafy is the inverse of unmetafy. zsh metafies NUL, the Meta byte itself
+// and its internal token bytes up to Marker.
+func metafy(command []byte) []byte {
+	out := make([]byte, 0, len(command))
+	for _, c := range command {
+		if c == 0 || (c >= zshMeta && c <= zshMarker) {
+			out = append(out, zshMeta, c^zshMetaMask)
+			continue
+		}
+
+		out = append(out, c)
+	}
+
+	return out
+}
@@ -7,9 +7,9 @@ import (
 )
 
 type CandidateOpts struct {
-	Limit      int
-	Dedupe     bool
-	FailFilter db.FailFilterMode
+	Limit  int
+	Dedupe bool
+	Filter db.CandidateFilter
 }
 
 func FetchCandidates(repo *db.HistoryRepo, opts CandidateOpts) ([]db.HistoryEntry, error) {
@@ -18,10 +18,30 @@ func FetchCandidates(repo *db.HistoryRepo, opts CandidateOpts) ([]db.HistoryEntr
 		limit = 10000
 	}
 
-	entries, err := repo.FetchCandidates(limit, opts.Dedupe, opts.FailFilter)
+	entries, err := repo.FetchCandidates(limit, opts.Dedupe, opts.Filter)
 	if err != nil {
 		return nil, fmt.Errorf("fetching history candidates: %w", err)
 	}
 
 	return entries, nil
 }
+
+func FetchCommandStats(repo *db.HistoryRepo, filter db.CandidateFilter) (map[string]db.CommandStats, error) {
+	stats, err := repo.CommandStats(filter)
+	if err != nil {
+		return nil, fmt.Errorf("fetching command stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// FetchTransitions returns how often each command followed prev within a
+// session.
+func FetchTransitions(repo *db.HistoryRepo, prev string) (map[string]int, error) {
+	transitions, err := repo.Transitions(prev)
+	if err != nil {
+		return nil, fmt.Errorf("fetching command transitions: %w", err)
+	}
+
+	return transitions, nil
+}
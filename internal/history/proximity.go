@@ -0,0 +1,103 @@
+package history
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FindGitRoot returns the nearest directory at or above dir that contains a
+// .git entry, or "" when dir is not inside a git repository.
+func FindGitRoot(dir string) string {
+	if dir == "" {
+		return ""
+	}
+
+	current := filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return ""
+		}
+
+		current = parent
+	}
+}
+
+// proximity scores how close an entry's directory is to the search
+// directory. Directories are walked up to their deepest shared ancestor and
+// the CWD bonus decays by ProximityDecay for every level on either side, so
+// an exact match gets the full bonus and a parent or sibling gets part of
+// it. Entries under GitRoot get GitRootBonus on top.
+type proximity struct {
+	cwd          []string
+	bonus        int
+	decay        float64
+	gitRoot      string
+	gitRootBonus int
+	cache        map[string]int
+}
+
+func newProximity(opts ScoringOpts) proximity {
+	return proximity{
+		cwd:          splitPath(opts.CWD),
+		bonus:        opts.CWDBonus,
+		decay:        opts.ProximityDecay,
+		gitRoot:      opts.GitRoot,
+		gitRootBonus: opts.GitRootBonus,
+		cache:        make(map[string]int),
+	}
+}
+
+func (p proximity) score(dir string) int {
+	if len(p.cwd) == 0 || dir == "" {
+		return 0
+	}
+
+	if score, ok := p.cache[dir]; ok {
+		return score
+	}
+
+	parts := splitPath(dir)
+
+	shared := 0
+	for shared < len(parts) && shared < len(p.cwd) && parts[shared] == p.cwd[shared] {
+		shared++
+	}
+
+	score := 0
+	if shared > 0 {
+		distance := len(parts) - shared + len(p.cwd) - shared
+		score = int(math.Round(float64(p.bonus) * math.Pow(p.decay, float64(distance))))
+	}
+
+	if p.gitRoot != "" && isWithin(dir, p.gitRoot) {
+		score += p.gitRootBonus
+	}
+
+	p.cache[dir] = score
+
+	return score
+}
+
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	return strings.FieldsFunc(filepath.ToSlash(filepath.Clean(path)), func(r rune) bool { return r == '/' })
+}
+
+func isWithin(dir string, root string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,57 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProximityScoreDecaysPerLevel(t *testing.T) {
+	opts := DefaultScoringOpts(filepath.FromSlash("/repo/pkg/api"))
+	opts.CWDBonus = 40
+	opts.ProximityDecay = 0.5
+	opts.GitRoot = filepath.FromSlash("/repo")
+	opts.GitRootBonus = 3
+
+	p := newProximity(opts)
+
+	tests := []struct {
+		dir  string
+		want int
+	}{
+		{dir: "/repo/pkg/api", want: 43},
+		{dir: "/repo/pkg", want: 23},
+		{dir: "/repo/pkg/web", want: 13},
+		{dir: "/repo", want: 13},
+		{dir: "/repository", want: 0},
+		{dir: "/other/pkg/api", want: 0},
+		{dir: "", want: 0},
+	}
+
+	for _, tc := range tests {
+		if got := p.score(filepath.FromSlash(tc.dir)); got != tc.want {
+			t.Errorf("score(%q) = %d, want %d", tc.dir, got, tc.want)
+		}
+	}
+}
+
+func TestFindGitRoot(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+
+	if got := FindGitRoot(nested); got == root {
+		t.Fatalf("FindGitRoot() = %q before .git exists", got)
+	}
+
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatalf("Mkdir() error: %v", err)
+	}
+
+	if got := FindGitRoot(nested); got != root {
+		t.Fatalf("FindGitRoot() = %q, want %q", got, root)
+	}
+}
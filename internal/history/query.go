@@ -0,0 +1,224 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+var ErrInvalidQualifier = errors.New("invalid qualifier")
+
+const (
+	qualifierDir     = "dir"
+	qualifierExit    = "exit"
+	qualifierHost    = "host"
+	qualifierSession = "session"
+	qualifierAfter   = "after"
+	qualifierBefore  = "before"
+	qualifierDur     = "dur"
+)
+
+// Query is a search query with its field qualifiers (dir:, exit:, host:,
+// session:, after:, before:, dur:) parsed out. Text is what remains for the
+// matcher and Filter holds the qualifiers as a candidate filter.
+type Query struct {
+	Text       string
+	Filter     db.CandidateFilter
+	Qualifiers []QualifierSpan
+	Err        error
+}
+
+// QualifierSpan locates a qualifier in the raw query by rune offsets. Err is
+// set when its value could not be parsed.
+type QualifierSpan struct {
+	Start int
+	End   int
+	Raw   string
+	Err   error
+}
+
+// Key identifies the query's qualifiers; queries with equal keys select the
+// same candidates.
+func (q Query) Key() string {
+	raws := make([]string, len(q.Qualifiers))
+	for i, span := range q.Qualifiers {
+		raws[i] = span.Raw
+	}
+
+	return strings.Join(raws, " ")
+}
+
+// ParseQuery splits raw into search text and qualifiers. Relative directories
+// are resolved against cwd and "~" against homeDir. Words with an unknown
+// key, like "origin:main", stay part of the text.
+func ParseQuery(raw string, now time.Time, cwd string, homeDir string) Query {
+	var q Query
+
+	q.Text = raw
+
+	runes := []rune(raw)
+
+	var (
+		segments []string
+		segStart int
+	)
+
+	for start := 0; start < len(runes); {
+		if unicode.IsSpace(runes[start]) {
+			start++
+
+			continue
+		}
+
+		end := start
+		for end < len(runes) && !unicode.IsSpace(runes[end]) {
+			end++
+		}
+
+		token := string(runes[start:end])
+
+		key, value, ok := strings.Cut(token, ":")
+		if ok && isQualifierKey(key) {
+			span := QualifierSpan{Start: start, End: end, Raw: token, Err: nil}
+			if value != "" {
+				span.Err = q.applyQualifier(key, value, now, cwd, homeDir)
+			}
+
+			if span.Err != nil && q.Err == nil {
+				q.Err = span.Err
+			}
+
+			q.Qualifiers = append(q.Qualifiers, span)
+			segments = append(segments, strings.TrimSpace(string(runes[segStart:start])))
+			segStart = end
+		}
+
+		start = end
+	}
+
+	if len(q.Qualifiers) == 0 {
+		return q
+	}
+
+	segments = append(segments, strings.TrimSpace(string(runes[segStart:])))
+
+	var kept []string
+
+	for _, segment := range segments {
+		if segment != "" {
+			kept = append(kept, segment)
+		}
+	}
+
+	q.Text = strings.Join(kept, " ")
+
+	return q
+}
+
+func isQualifierKey(key string) bool {
+	switch key {
+	case qualifierDir, qualifierExit, qualifierHost, qualifierSession, qualifierAfter, qualifierBefore, qualifierDur:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *Query) applyQualifier(key string, value string, now time.Time, cwd string, homeDir string) error {
+	switch key {
+	case qualifierDir:
+		q.Filter.Directory = resolveQueryDir(value, cwd, homeDir)
+	case qualifierHost:
+		q.Filter.Hostname = value
+	case qualifierSession:
+		q.Filter.SessionID = value
+	case qualifierExit:
+		c, err := parseComparison(value, db.CompareEq, func(s string) (int64, error) {
+			return strconv.ParseInt(s, 10, 64)
+		})
+		if err != nil {
+			return fmt.Errorf("%w exit:%s: expected an exit code like 0, !0 or >1", ErrInvalidQualifier, value)
+		}
+
+		q.Filter.ExitCode = append(q.Filter.ExitCode, c)
+	case qualifierDur:
+		c, err := parseComparison(value, db.CompareGe, func(s string) (int64, error) {
+			d, err := time.ParseDuration(s)
+			return d.Milliseconds(), err
+		})
+		if err != nil {
+			return fmt.Errorf("%w dur:%s: expected a duration like >30s or <500ms", ErrInvalidQualifier, value)
+		}
+
+		q.Filter.Duration = append(q.Filter.Duration, c)
+	case qualifierAfter, qualifierBefore:
+		ms, err := parseQueryTime(value, now)
+		if err != nil {
+			return fmt.Errorf("%w %s:%s: expected today, yesterday, %s", ErrInvalidQualifier, key, value, timeBoundFmt)
+		}
+
+		if key == qualifierAfter {
+			q.Filter.SinceMs = ms
+		} else {
+			q.Filter.UntilMs = ms
+		}
+	}
+
+	return nil
+}
+
+// parseComparison reads an optional operator (=, !=, !, <, <=, >, >=)
+// followed by a value. Without an operator def is used.
+func parseComparison(value string, def db.CompareOp, parse func(string) (int64, error)) (db.Comparison, error) {
+	op := def
+
+	for _, candidate := range []db.CompareOp{db.CompareNe, db.CompareLe, db.CompareGe, db.CompareEq, db.CompareLt, db.CompareGt} {
+		if rest, ok := strings.CutPrefix(value, string(candidate)); ok {
+			op, value = candidate, rest
+
+			break
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(value, "!"); ok && op == def {
+		op, value = db.CompareNe, rest
+	}
+
+	n, err := parse(value)
+	if err != nil {
+		return db.Comparison{}, fmt.Errorf("parsing %q: %w", value, err)
+	}
+
+	return db.Comparison{Op: op, Value: n}, nil
+}
+
+func parseQueryTime(value string, now time.Time) (int64, error) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch value {
+	case "today":
+		return midnight.UnixMilli(), nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1).UnixMilli(), nil
+	default:
+		return ParseTimeBound(value, now)
+	}
+}
+
+func resolveQueryDir(value string, cwd string, homeDir string) string {
+	if value == "~" || strings.HasPrefix(value, "~/") || strings.HasPrefix(value, `~\`) {
+		value = filepath.Join(homeDir, value[1:])
+	}
+
+	if !filepath.IsAbs(value) && cwd != "" {
+		value = filepath.Join(cwd, value)
+	}
+
+	return filepath.Clean(value)
+}
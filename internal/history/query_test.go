@@ -0,0 +1,67 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+func TestParseQuerySeparatesQualifiers(t *testing.T) {
+	now := time.Date(2026, time.March, 10, 15, 30, 0, 0, time.UTC)
+	home := filepath.Join(string(filepath.Separator), "home", "me")
+
+	q := ParseQuery("docker dir:~/src/api  exit:!0 push origin:main dur:>30s after:yesterday host:build01", now, "/work", home)
+	if q.Err != nil {
+		t.Fatalf("ParseQuery() error: %v", q.Err)
+	}
+
+	if want := "docker push origin:main"; q.Text != want {
+		t.Fatalf("Text = %q, want %q", q.Text, want)
+	}
+
+	want := db.CandidateFilter{
+		Directory: filepath.Join(home, "src", "api"),
+		Hostname:  "build01",
+		SinceMs:   time.Date(2026, time.March, 9, 0, 0, 0, 0, time.UTC).UnixMilli(),
+		ExitCode:  []db.Comparison{{Op: db.CompareNe, Value: 0}},
+		Duration:  []db.Comparison{{Op: db.CompareGt, Value: 30_000}},
+	}
+	if !reflect.DeepEqual(q.Filter, want) {
+		t.Fatalf("Filter = %+v, want %+v", q.Filter, want)
+	}
+
+	if len(q.Qualifiers) != 5 {
+		t.Fatalf("len(Qualifiers) = %d, want 5", len(q.Qualifiers))
+	}
+
+	if span := q.Qualifiers[0]; span.Start != 7 || span.End != 20 {
+		t.Fatalf("Qualifiers[0] = %+v, want runes 7-20", span)
+	}
+}
+
+func TestParseQueryReportsInvalidQualifiers(t *testing.T) {
+	now := time.Date(2026, time.March, 10, 15, 30, 0, 0, time.UTC)
+
+	for _, raw := range []string{"exit:abc", "dur:>fast", "before:someday"} {
+		q := ParseQuery("git "+raw, now, "", "")
+		if !errors.Is(q.Err, ErrInvalidQualifier) {
+			t.Fatalf("ParseQuery(%q) error = %v, want %v", raw, q.Err, ErrInvalidQualifier)
+		}
+
+		if len(q.Qualifiers) != 1 || q.Qualifiers[0].Err == nil {
+			t.Fatalf("ParseQuery(%q) qualifiers = %+v, want one invalid span", raw, q.Qualifiers)
+		}
+	}
+
+	if q := ParseQuery("ls dir:", now, "", ""); q.Err != nil || q.Text != "ls" {
+		t.Fatalf("ParseQuery(incomplete) = %+v, want no error and text %q", q, "ls")
+	}
+
+	if q := ParseQuery("git  commit", now, "", ""); q.Text != "git  commit" || q.Key() != "" {
+		t.Fatalf("ParseQuery(no qualifiers) = %+v, want text unchanged", q)
+	}
+}
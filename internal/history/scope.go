@@ -0,0 +1,150 @@
+package history
+
+import "github.com/zigai/zgod/internal/db"
+
+// Scope limits search results to directories related to the current one.
+type Scope int
+
+const (
+	// ScopeGlobal searches all history.
+	ScopeGlobal Scope = iota
+	// ScopeRepo searches the git repository containing the current directory.
+	ScopeRepo
+	// ScopeSubtree searches the current directory and everything below it.
+	ScopeSubtree
+	// ScopeExact searches only the current directory.
+	ScopeExact
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeGlobal:
+		return "global"
+	case ScopeRepo:
+		return "repo"
+	case ScopeSubtree:
+		return "subtree"
+	case ScopeExact:
+		return "exact"
+	}
+
+	return "global"
+}
+
+// ParseScope accepts the scope names plus "normal" and "cwd", the names of
+// the global and exact scopes before repository and subtree scopes existed.
+func ParseScope(s string) (Scope, bool) {
+	switch s {
+	case "", "global", "normal":
+		return ScopeGlobal, true
+	case "repo":
+		return ScopeRepo, true
+	case "subtree":
+		return ScopeSubtree, true
+	case "exact", "cwd":
+		return ScopeExact, true
+	default:
+		return ScopeGlobal, false
+	}
+}
+
+// Next cycles from wider to narrower scopes and back to global, skipping
+// scopes that cwd and gitRoot cannot provide or that would repeat the
+// previous one.
+func (s Scope) Next(cwd string, gitRoot string) Scope {
+	next := s
+	for {
+		switch next {
+		case ScopeGlobal:
+			next = ScopeRepo
+		case ScopeRepo:
+			next = ScopeSubtree
+		case ScopeSubtree:
+			next = ScopeExact
+		case ScopeExact:
+			return ScopeGlobal
+		}
+
+		if next.Resolve(cwd, gitRoot) != next || (next == ScopeSubtree && cwd == gitRoot) {
+			continue
+		}
+
+		return next
+	}
+}
+
+// Resolve narrows a scope that cannot apply: the repository scope falls
+// back to the subtree outside a git repository, and directory scopes fall
+// back to global without a current directory.
+func (s Scope) Resolve(cwd string, gitRoot string) Scope {
+	if cwd == "" {
+		return ScopeGlobal
+	}
+
+	if s == ScopeRepo && gitRoot == "" {
+		return ScopeSubtree
+	}
+
+	return s
+}
+
+// directory returns the directory to filter on and whether to match it
+// exactly.
+func (s Scope) directory(cwd string, gitRoot string) (string, bool) {
+	switch s.Resolve(cwd, gitRoot) {
+	case ScopeRepo:
+		return gitRoot, false
+	case ScopeSubtree:
+		return cwd, false
+	case ScopeExact:
+		return cwd, true
+	case ScopeGlobal:
+	}
+
+	return "", false
+}
+
+// SearchScope is a directory scope plus optional restrictions to the
+// current shell session and host.
+type SearchScope struct {
+	Directory Scope
+	Session   bool
+	Host      bool
+}
+
+// ParseSearchScope accepts the directory scope names plus "session" and
+// "host", which restrict all history to the current session or host.
+func ParseSearchScope(s string) (SearchScope, bool) {
+	scope := SearchScope{Directory: ScopeGlobal, Session: false, Host: false}
+
+	switch s {
+	case "session":
+		scope.Session = true
+		return scope, true
+	case "host":
+		scope.Host = true
+		return scope, true
+	}
+
+	directory, ok := ParseScope(s)
+	scope.Directory = directory
+
+	return scope, ok
+}
+
+// Filter returns the database scope. Session and host restrictions are
+// dropped when the session ID or hostname is unknown.
+func (s SearchScope) Filter(cwd string, gitRoot string, sessionID string, hostname string) db.Scope {
+	directory, exact := s.Directory.directory(cwd, gitRoot)
+	scope := db.Scope{Directory: directory, Exact: exact, SessionID: "", Hostname: ""}
+
+	if s.Session {
+		scope.SessionID = sessionID
+	}
+
+	if s.Host {
+		scope.Hostname = hostname
+	}
+
+	return scope
+}
@@ -0,0 +1,58 @@
+package history
+
+import "testing"
+
+func TestScopeNextSkipsUnavailableScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		cwd     string
+		gitRoot string
+		want    []Scope
+	}{
+		{name: "in repo", cwd: "/r/pkg", gitRoot: "/r", want: []Scope{ScopeRepo, ScopeSubtree, ScopeExact, ScopeGlobal}},
+		{name: "at repo root", cwd: "/r", gitRoot: "/r", want: []Scope{ScopeRepo, ScopeExact, ScopeGlobal}},
+		{name: "outside repo", cwd: "/tmp", gitRoot: "", want: []Scope{ScopeSubtree, ScopeExact, ScopeGlobal}},
+		{name: "no cwd", cwd: "", gitRoot: "", want: []Scope{ScopeGlobal}},
+	}
+
+	for _, tc := range tests {
+		scope := ScopeGlobal
+		for i, want := range tc.want {
+			scope = scope.Next(tc.cwd, tc.gitRoot)
+			if scope != want {
+				t.Fatalf("%s: step %d = %v, want %v", tc.name, i, scope, want)
+			}
+		}
+	}
+}
+
+func TestParseScopeAcceptsLegacyNames(t *testing.T) {
+	for input, want := range map[string]Scope{"normal": ScopeGlobal, "cwd": ScopeExact, "repo": ScopeRepo, "subtree": ScopeSubtree} {
+		if got, ok := ParseScope(input); !ok || got != want {
+			t.Fatalf("ParseScope(%q) = %v, %v; want %v", input, got, ok, want)
+		}
+	}
+
+	if _, ok := ParseScope("home"); ok {
+		t.Fatal("ParseScope(home) succeeded, want failure")
+	}
+}
+
+func TestSearchScopeFilter(t *testing.T) {
+	scope, ok := ParseSearchScope("session")
+	if !ok || !scope.Session || scope.Host || scope.Directory != ScopeGlobal {
+		t.Fatalf("ParseSearchScope(session) = %+v, %v", scope, ok)
+	}
+
+	scope.Directory = ScopeRepo
+	scope.Host = true
+
+	got := scope.Filter("/r/pkg", "/r", "s1", "laptop")
+	if got.Directory != "/r" || got.Exact || got.SessionID != "s1" || got.Hostname != "laptop" {
+		t.Fatalf("Filter() = %+v", got)
+	}
+
+	if got = scope.Filter("/r/pkg", "/r", "", ""); got.SessionID != "" || got.Hostname != "" {
+		t.Fatalf("Filter() without session and host = %+v", got)
+	}
+}
@@ -1,50 +1,157 @@
 package history
 
 import (
+	"math"
 	"sort"
+	"time"
 
+	"github.com/zigai/zgod/internal/config"
 	"github.com/zigai/zgod/internal/db"
 	"github.com/zigai/zgod/internal/match"
 )
 
 const (
-	defaultScoringCWDBonus    = 50
-	defaultScoringRecencyBase = 10
-	scoringRecencyIndexStep   = 100
+	defaultScoringCWDBonus  = 50
+	scoringRecencyIndexStep = 100
 )
 
+// Ranking selects what orders results beyond the match score.
+type Ranking int
+
+const (
+	// RankingFrecency combines run count, time decay and success ratio.
+	RankingFrecency Ranking = iota
+	// RankingRecency favors entries near the top of the newest-first list.
+	RankingRecency
+	// RankingMatch orders by match score alone.
+	RankingMatch
+)
+
+func ParseRanking(s string) (Ranking, bool) {
+	switch s {
+	case "frecency", "":
+		return RankingFrecency, true
+	case "recency":
+		return RankingRecency, true
+	case "match":
+		return RankingMatch, true
+	default:
+		return RankingFrecency, false
+	}
+}
+
 type ScoredEntry struct {
 	Entry      db.HistoryEntry
 	MatchInfo  match.Match
 	FinalScore int
 }
 
+// ScoringOpts weighs the parts of an entry's final score. SessionID and
+// Hostname identify the searching shell for the same-session and same-host
+// bonuses. GitRoot is the repository containing CWD, if any.
 type ScoringOpts struct {
-	CWD         string
-	CWDBonus    int
+	CWD       string
+	GitRoot   string
+	SessionID string
+	Hostname  string
+
+	MatchWeight         float64
+	CWDBonus            int
+	ProximityDecay      float64
+	GitRootBonus        int
+	SessionBonus        int
+	HostBonus           int
+	FailurePenalty      int
+	LongDurationMs      int64
+	LongDurationPenalty int
+
+	Ranking     Ranking
 	RecencyBase int
+
+	NowMs           int64
+	HalfLife        time.Duration
+	RecencyWeight   float64
+	FrequencyWeight float64
+	SuccessWeight   float64
+	// Stats holds per-command run counts for frecency. Commands missing from
+	// it are scored from their own row.
+	Stats map[string]db.CommandStats
+
+	// Transitions counts the commands that followed the session's previous
+	// command; each gets TransitionWeight times its share of them.
+	TransitionWeight float64
+	Transitions      map[string]int
+
+	// Selections counts the commands chosen before for similar queries;
+	// each gets SelectionWeight times its share of them.
+	SelectionWeight float64
+	Selections      map[string]int
 }
 
 func DefaultScoringOpts(cwd string) ScoringOpts {
+	return NewScoringOpts(cwd, config.DefaultRanking())
+}
+
+// NewScoringOpts builds scoring options from a [ranking] config.
+func NewScoringOpts(cwd string, ranking config.RankingConfig) ScoringOpts {
 	return ScoringOpts{
-		CWD:         cwd,
-		CWDBonus:    defaultScoringCWDBonus,
-		RecencyBase: defaultScoringRecencyBase,
+		CWD:       cwd,
+		GitRoot:   "",
+		SessionID: "",
+		Hostname:  "",
+
+		MatchWeight:         ranking.MatchWeight,
+		CWDBonus:            config.IntDefault(ranking.CWDBonus, defaultScoringCWDBonus),
+		ProximityDecay:      ranking.ProximityDecay,
+		GitRootBonus:        ranking.GitRootBonus,
+		SessionBonus:        ranking.SessionBonus,
+		HostBonus:           ranking.HostBonus,
+		FailurePenalty:      ranking.FailurePenalty,
+		LongDurationMs:      ranking.LongDuration.Milliseconds(),
+		LongDurationPenalty: ranking.LongDurationPenalty,
+
+		Ranking:     RankingFrecency,
+		RecencyBase: ranking.RecencyBase,
+
+		NowMs:           time.Now().UnixMilli(),
+		HalfLife:        ranking.HalfLife,
+		RecencyWeight:   ranking.RecencyWeight,
+		FrequencyWeight: ranking.FrequencyWeight,
+		SuccessWeight:   ranking.SuccessWeight,
+		Stats:           nil,
+
+		TransitionWeight: ranking.TransitionWeight,
+		Transitions:      nil,
+
+		SelectionWeight: ranking.SelectionWeight,
+		Selections:      nil,
 	}
 }
 
+// ScoreAndSort ranks matched entries. matches index into entries, which are
+// ordered newest first.
 func ScoreAndSort(entries []db.HistoryEntry, matches []match.Match, opts ScoringOpts) []ScoredEntry {
+	transitionTotal := countTotal(opts.Transitions)
+	selectionTotal := countTotal(opts.Selections)
+	proximity := newProximity(opts)
+
 	scored := make([]ScoredEntry, len(matches))
 	for i, m := range matches {
 		entry := entries[m.Index]
-		score := m.Score
+		score := int(math.Round(opts.MatchWeight * float64(m.Score)))
+		score += contextScore(entry, opts)
+		score += proximity.score(entry.Directory)
 
-		if opts.CWD != "" && entry.Directory == opts.CWD {
-			score += opts.CWDBonus
-		}
+		score += shareBoost(opts.TransitionWeight, opts.Transitions[entry.Command], transitionTotal)
+		score += shareBoost(opts.SelectionWeight, opts.Selections[entry.Command], selectionTotal)
 
-		recency := max(opts.RecencyBase-(m.Index/scoringRecencyIndexStep), 0)
-		score += recency
+		switch opts.Ranking {
+		case RankingFrecency:
+			score += frecency(entry, opts)
+		case RankingRecency:
+			score += max(opts.RecencyBase-(m.Index/scoringRecencyIndexStep), 0)
+		case RankingMatch:
+		}
 
 		scored[i] = ScoredEntry{
 			Entry:      entry,
@@ -59,3 +166,78 @@ func ScoreAndSort(entries []db.HistoryEntry, matches []match.Match, opts Scoring
 
 	return scored
 }
+
+// shareBoost scales weight by count's share of total.
+func shareBoost(weight float64, count int, total int) int {
+	if count <= 0 || total <= 0 {
+		return 0
+	}
+
+	return int(math.Round(weight * float64(count) / float64(total)))
+}
+
+func countTotal(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	return total
+}
+
+// contextScore adds the bonuses and penalties that depend on where and how
+// the entry ran.
+func contextScore(entry db.HistoryEntry, opts ScoringOpts) int {
+	score := 0
+
+	if opts.SessionID != "" && entry.SessionID == opts.SessionID {
+		score += opts.SessionBonus
+	}
+
+	if opts.Hostname != "" && entry.Hostname == opts.Hostname {
+		score += opts.HostBonus
+	}
+
+	if entry.ExitCode != 0 {
+		score -= opts.FailurePenalty
+	}
+
+	if opts.LongDurationMs > 0 && entry.Duration >= opts.LongDurationMs {
+		score -= opts.LongDurationPenalty
+	}
+
+	return score
+}
+
+// ScoreAll ranks every entry, as shown for an empty query.
+func ScoreAll(entries []db.HistoryEntry, opts ScoringOpts) []ScoredEntry {
+	matches := make([]match.Match, len(entries))
+	for i := range entries {
+		matches[i] = match.Match{Index: i, Score: 0, MatchedRanges: nil}
+	}
+
+	return ScoreAndSort(entries, matches, opts)
+}
+
+// frecency scores an entry from how recently it ran, with exponential decay
+// over HalfLife, how often its command ran and how often that succeeded.
+func frecency(entry db.HistoryEntry, opts ScoringOpts) int {
+	stats, ok := opts.Stats[entry.Command]
+	if !ok || stats.Runs < 1 {
+		stats = db.CommandStats{Runs: max(int64(entry.RunCount), 1), Successes: 0}
+		if entry.ExitCode == 0 {
+			stats.Successes = stats.Runs
+		}
+	}
+
+	decay := 0.0
+	if halfLifeMs := opts.HalfLife.Milliseconds(); halfLifeMs > 0 {
+		ageMs := max(opts.NowMs-entry.TsMs, 0)
+		decay = math.Exp2(-float64(ageMs) / float64(halfLifeMs))
+	}
+
+	frequency := math.Log2(1 + float64(stats.Runs))
+	success := float64(stats.Successes) / float64(stats.Runs)
+
+	return int(math.Round(opts.RecencyWeight*decay + opts.FrequencyWeight*frequency + opts.SuccessWeight*success))
+}
@@ -0,0 +1,151 @@
+package history
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zigai/zgod/internal/config"
+	"github.com/zigai/zgod/internal/db"
+	"github.com/zigai/zgod/internal/match"
+)
+
+func scoredCommands(scored []ScoredEntry) []string {
+	commands := make([]string, len(scored))
+	for i, s := range scored {
+		commands[i] = s.Entry.Command
+	}
+
+	return commands
+}
+
+func TestScoreAllFrecencyFavorsFrequentSuccessfulCommands(t *testing.T) {
+	now := time.Date(2026, time.March, 10, 12, 0, 0, 0, time.UTC)
+	hour := time.Hour.Milliseconds()
+
+	// Newest first, as returned by FetchCandidates.
+	entries := []db.HistoryEntry{
+		{TsMs: now.UnixMilli() - hour, Command: "vim notes", RunCount: 1},
+		{TsMs: now.UnixMilli() - 2*hour, ExitCode: 1, Command: "make flaky", RunCount: 1},
+		{TsMs: now.UnixMilli() - 3*hour, Command: "make test", RunCount: 1},
+	}
+
+	opts := DefaultScoringOpts("")
+	opts.NowMs = now.UnixMilli()
+	opts.Stats = map[string]db.CommandStats{
+		"vim notes":  {Runs: 1, Successes: 1},
+		"make flaky": {Runs: 40, Successes: 2},
+		"make test":  {Runs: 40, Successes: 40},
+	}
+
+	got := scoredCommands(ScoreAll(entries, opts))
+	if want := []string{"make test", "make flaky", "vim notes"}; !slices.Equal(got, want) {
+		t.Fatalf("frecency order = %v, want %v", got, want)
+	}
+
+	opts.Ranking = RankingRecency
+	if got = scoredCommands(ScoreAll(entries, opts)); got[0] != "vim notes" {
+		t.Fatalf("recency order = %v, want vim notes first", got)
+	}
+}
+
+func TestFrecencyDecaysWithAge(t *testing.T) {
+	opts := DefaultScoringOpts("")
+	opts.NowMs = 10 * opts.HalfLife.Milliseconds()
+
+	fresh := frecency(db.HistoryEntry{TsMs: opts.NowMs, Command: "a", ExitCode: 1}, opts)
+	halfLifeOld := frecency(db.HistoryEntry{TsMs: opts.NowMs - opts.HalfLife.Milliseconds(), Command: "a", ExitCode: 1}, opts)
+
+	// One run and no successes leave the recency term plus log2(2) frequency.
+	if want := int(opts.RecencyWeight + opts.FrequencyWeight); fresh != want {
+		t.Fatalf("fresh frecency = %d, want %d", fresh, want)
+	}
+
+	if want := int(opts.RecencyWeight/2 + opts.FrequencyWeight); halfLifeOld != want {
+		t.Fatalf("frecency after one half-life = %d, want %d", halfLifeOld, want)
+	}
+}
+
+func TestParseRanking(t *testing.T) {
+	for value, want := range map[string]Ranking{"": RankingFrecency, "recency": RankingRecency, "match": RankingMatch} {
+		if got, ok := ParseRanking(value); !ok || got != want {
+			t.Fatalf("ParseRanking(%q) = %v, %v; want %v", value, got, ok, want)
+		}
+	}
+
+	if _, ok := ParseRanking("popular"); ok {
+		t.Fatal("ParseRanking(popular) ok = true, want false")
+	}
+}
+
+func TestScoreAllBoostsLikelyNextCommands(t *testing.T) {
+	entries := []db.HistoryEntry{
+		{TsMs: 3000, Command: "ls"},
+		{TsMs: 2000, Command: "git push"},
+		{TsMs: 1000, Command: "git commit"},
+	}
+
+	opts := DefaultScoringOpts("")
+	opts.Ranking = RankingMatch
+	opts.Transitions = map[string]int{"git commit": 3, "git push": 1}
+
+	got := scoredCommands(ScoreAll(entries, opts))
+	if want := []string{"git commit", "git push", "ls"}; !slices.Equal(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+}
+
+func TestScoreAllBoostsPreviouslySelectedCommands(t *testing.T) {
+	entries := []db.HistoryEntry{
+		{TsMs: 3000, Command: "git status"},
+		{TsMs: 2000, Command: "git stash"},
+	}
+
+	opts := DefaultScoringOpts("")
+	opts.Ranking = RankingMatch
+	opts.Selections = map[string]int{"git stash": 4}
+
+	got := scoredCommands(ScoreAll(entries, opts))
+	if want := []string{"git stash", "git status"}; !slices.Equal(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+}
+
+func TestSelectionQueryNormalizes(t *testing.T) {
+	if got := SelectionQuery("  Git   ST "); got != "git st" {
+		t.Fatalf("SelectionQuery() = %q, want %q", got, "git st")
+	}
+}
+
+func TestScoreAndSortAppliesRankingWeights(t *testing.T) {
+	entries := []db.HistoryEntry{
+		{Command: "plain"},
+		{Command: "same session", SessionID: "s1"},
+		{Command: "same host", Hostname: "box"},
+		{Command: "failed", ExitCode: 1},
+		{Command: "slow", Duration: 600_000},
+	}
+
+	opts := NewScoringOpts("", config.DefaultRanking())
+	opts.Ranking = RankingMatch
+	opts.SessionID = "s1"
+	opts.Hostname = "box"
+	opts.MatchWeight = 2
+	opts.SessionBonus = 7
+	opts.HostBonus = 3
+	opts.FailurePenalty = 4
+	opts.LongDurationMs = 60_000
+	opts.LongDurationPenalty = 5
+
+	matches := make([]match.Match, len(entries))
+	for i := range entries {
+		matches[i] = match.Match{Index: i, Score: 10}
+	}
+
+	want := map[string]int{"plain": 20, "same session": 27, "same host": 23, "failed": 16, "slow": 15}
+	for _, s := range ScoreAndSort(entries, matches, opts) {
+		if s.FinalScore != want[s.Entry.Command] {
+			t.Errorf("%s score = %d, want %d", s.Entry.Command, s.FinalScore, want[s.Entry.Command])
+		}
+	}
+}
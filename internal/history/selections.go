@@ -0,0 +1,44 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+// SelectionQuery normalizes a query for storing and looking up selections,
+// so case and spacing differences still count as the same query.
+func SelectionQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// RecordSelection remembers that entry, shown at position, was accepted for
+// query in the given match mode.
+func RecordSelection(repo *db.HistoryRepo, query string, mode string, entry db.HistoryEntry, position int) error {
+	err := repo.RecordSelection(db.Selection{
+		TsMs:      time.Now().UnixMilli(),
+		Query:     SelectionQuery(query),
+		Mode:      mode,
+		HistoryID: entry.ID,
+		Command:   entry.Command,
+		Position:  position,
+	})
+	if err != nil {
+		return fmt.Errorf("recording selection: %w", err)
+	}
+
+	return nil
+}
+
+// FetchSelectionCounts returns how often each command was chosen for
+// queries similar to query.
+func FetchSelectionCounts(repo *db.HistoryRepo, query string) (map[string]int, error) {
+	counts, err := repo.SelectionCounts(SelectionQuery(query))
+	if err != nil {
+		return nil, fmt.Errorf("fetching selection counts: %w", err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,54 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidTimeBound = errors.New("invalid time bound")
+
+const (
+	hoursPerDay  = 24
+	daysPerWeek  = 7
+	daysPerYear  = 365
+	timeBoundFmt = "a relative age like 30d, 12h or 1y, a date like 2024-01-31, or an RFC 3339 timestamp"
+)
+
+var relativeTimeUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': hoursPerDay * time.Hour,
+	'w': daysPerWeek * hoursPerDay * time.Hour,
+	'y': daysPerYear * hoursPerDay * time.Hour,
+}
+
+// ParseTimeBound converts a --since or --until value into Unix milliseconds.
+// Relative values count back from now. An empty value returns 0, meaning
+// unbounded.
+func ParseTimeBound(value string, now time.Time) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	if n := len(value); n > 1 {
+		if unit, ok := relativeTimeUnits[value[n-1]]; ok {
+			count, err := strconv.ParseInt(value[:n-1], 10, 64)
+			if err == nil && count >= 0 {
+				return now.Add(-time.Duration(count) * unit).UnixMilli(), nil
+			}
+		}
+	}
+
+	if t, err := time.ParseInLocation(time.DateOnly, value, now.Location()); err == nil {
+		return t.UnixMilli(), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UnixMilli(), nil
+	}
+
+	return 0, fmt.Errorf("%w %q: expected %s", ErrInvalidTimeBound, value, timeBoundFmt)
+}
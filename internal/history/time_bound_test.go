@@ -0,0 +1,37 @@
+package history
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{value: "", want: 0},
+		{value: "30d", want: now.AddDate(0, 0, -30).UnixMilli()},
+		{value: "12h", want: now.Add(-12 * time.Hour).UnixMilli()},
+		{value: "2024-01-31", want: time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC).UnixMilli()},
+		{value: "2024-01-31T08:00:00Z", want: time.Date(2024, time.January, 31, 8, 0, 0, 0, time.UTC).UnixMilli()},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseTimeBound(tc.value, now)
+		if err != nil {
+			t.Fatalf("ParseTimeBound(%q) error: %v", tc.value, err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("ParseTimeBound(%q) = %d, want %d", tc.value, got, tc.want)
+		}
+	}
+
+	if _, err := ParseTimeBound("yesterday", now); !errors.Is(err, ErrInvalidTimeBound) {
+		t.Fatalf("ParseTimeBound(yesterday) error = %v, want %v", err, ErrInvalidTimeBound)
+	}
+}
@@ -0,0 +1,137 @@
+package histsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+// ChangeVersion is the change log format version. Readers reject changes
+// from newer versions instead of misapplying them. Version 2 adds
+// first_ts_ms, and only changes that need it are written as version 2.
+const ChangeVersion = 2
+
+// Op is the kind of change recorded in a change log.
+type Op string
+
+const (
+	OpInsert Op = "insert"
+	OpDelete Op = "delete"
+)
+
+var (
+	errChangeVersion = errors.New("unsupported change version")
+	errChangeOp      = errors.New("unsupported change operation")
+)
+
+// Change is one entry of a host's change log. Deletions only carry the
+// fields that identify the deleted entry.
+type Change struct {
+	Version   int    `json:"v"`
+	Op        Op     `json:"op"`
+	TsMs      int64  `json:"ts_ms"` //nolint:tagliatelle // matches the database column
+	Duration  int64  `json:"duration,omitempty"`
+	ExitCode  int    `json:"exit_code,omitempty"` //nolint:tagliatelle // matches the database column
+	Command   string `json:"command"`
+	Directory string `json:"directory"`
+	SessionID string `json:"session_id"` //nolint:tagliatelle // matches the database column
+	Hostname  string `json:"hostname"`
+	RunCount  int    `json:"run_count,omitempty"` //nolint:tagliatelle // matches the database column
+	Shell     string `json:"shell,omitempty"`
+	// FirstTsMs identifies an entry whose timestamp moved when repeated runs
+	// were folded into it. It is omitted when equal to TsMs.
+	FirstTsMs int64 `json:"first_ts_ms,omitempty"` //nolint:tagliatelle // matches the database column
+}
+
+func insertChange(e db.HistoryEntry) Change {
+	version, first := 1, int64(0)
+	if key := e.SyncKey(); key.TsMs != e.TsMs {
+		version, first = ChangeVersion, key.TsMs
+	}
+
+	return Change{
+		Version:   version,
+		Op:        OpInsert,
+		TsMs:      e.TsMs,
+		Duration:  e.Duration,
+		ExitCode:  e.ExitCode,
+		Command:   e.Command,
+		Directory: e.Directory,
+		SessionID: e.SessionID,
+		Hostname:  e.Hostname,
+		RunCount:  e.RunCount,
+		Shell:     e.Shell,
+		FirstTsMs: first,
+	}
+}
+
+func deleteChange(k db.SyncKey) Change {
+	return Change{
+		Version:   1,
+		Op:        OpDelete,
+		TsMs:      k.TsMs,
+		Duration:  0,
+		ExitCode:  0,
+		Command:   k.Command,
+		Directory: k.Directory,
+		SessionID: k.SessionID,
+		Hostname:  k.Hostname,
+		RunCount:  0,
+		Shell:     "",
+		FirstTsMs: 0,
+	}
+}
+
+func (c Change) entry() db.HistoryEntry {
+	return db.HistoryEntry{
+		ID:        0,
+		TsMs:      c.TsMs,
+		Duration:  c.Duration,
+		ExitCode:  c.ExitCode,
+		Command:   c.Command,
+		Directory: c.Directory,
+		SessionID: c.SessionID,
+		Hostname:  c.Hostname,
+		RunCount:  c.RunCount,
+		Shell:     c.Shell,
+		FirstTsMs: c.FirstTsMs,
+	}
+}
+
+func (c Change) validate() error {
+	if c.Version < 1 || c.Version > ChangeVersion {
+		return fmt.Errorf("%w %d", errChangeVersion, c.Version)
+	}
+
+	if c.Op != OpInsert && c.Op != OpDelete {
+		return fmt.Errorf("%w %q", errChangeOp, c.Op)
+	}
+
+	return nil
+}
+
+// EncodeChange returns the change log line for c, without a trailing newline.
+func EncodeChange(c Change) ([]byte, error) {
+	line, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("encoding change: %w", err)
+	}
+
+	return line, nil
+}
+
+// DecodeChange parses and validates a change log line.
+func DecodeChange(line []byte) (Change, error) {
+	var c Change
+	if err := json.Unmarshal(line, &c); err != nil {
+		return Change{}, fmt.Errorf("decoding change: %w", err)
+	}
+
+	if err := c.validate(); err != nil {
+		return Change{}, err
+	}
+
+	return c, nil
+}
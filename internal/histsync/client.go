@@ -0,0 +1,194 @@
+package histsync
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pushBatchSize     = 1000
+	clientTimeout     = 60 * time.Second
+	maxErrorBodyBytes = 1024
+)
+
+var errServerStatus = errors.New("sync server returned an error")
+
+// Client talks to a sync server on behalf of one host.
+type Client struct {
+	baseURL string
+	token   string
+	key     []byte
+	http    *http.Client
+}
+
+// NewClient returns a client for the server at baseURL. A nil key sends
+// changes unencrypted.
+func NewClient(baseURL string, token string, key []byte) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		key:     key,
+		http:    &http.Client{Timeout: clientTimeout},
+	}
+}
+
+// SyncServer uploads this machine's pending changes to the server and merges
+// the changes uploaded by every other host.
+func SyncServer(database *sql.DB, client *Client) (Summary, error) {
+	target := "server:" + client.baseURL
+
+	pending, err := CollectPending(database, target)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	for start := 0; start < len(pending.Changes); start += pushBatchSize {
+		batch := pending.Changes[start:min(start+pushBatchSize, len(pending.Changes))]
+		if err = client.push(batch); err != nil {
+			return Summary{}, err
+		}
+	}
+
+	if err = pending.Delivered(database); err != nil {
+		return Summary{}, err
+	}
+
+	summary, err := pullFromServer(database, client, target)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary.Pushed = len(pending.Changes)
+	summary.Sources = 1
+
+	return summary, nil
+}
+
+func pullFromServer(database *sql.DB, client *Client, source string) (Summary, error) {
+	var summary Summary
+
+	for {
+		cursor, err := SourceCursor(database, source)
+		if err != nil {
+			return Summary{}, err
+		}
+
+		resp, err := client.pull(cursor)
+		if err != nil {
+			return Summary{}, err
+		}
+
+		changes := make([]Change, 0, len(resp.Changes))
+		for _, pulled := range resp.Changes {
+			change, decodeErr := client.decode(pulled.Payload)
+			if decodeErr != nil {
+				return Summary{}, fmt.Errorf("reading change %d: %w", pulled.Seq, decodeErr)
+			}
+
+			changes = append(changes, change)
+		}
+
+		if resp.Next != cursor {
+			merged, mergeErr := Merge(database, source, changes, resp.Next)
+			if mergeErr != nil {
+				return Summary{}, mergeErr
+			}
+
+			summary.Merged += merged.Merged
+			summary.Deleted += merged.Deleted
+		}
+
+		if !resp.More || resp.Next == cursor {
+			return summary, nil
+		}
+	}
+}
+
+func (c *Client) push(changes []Change) error {
+	req := pushRequest{Payloads: make([]string, 0, len(changes))}
+
+	for _, change := range changes {
+		line, err := EncodeChange(change)
+		if err != nil {
+			return err
+		}
+
+		payload, err := sealPayload(c.key, line)
+		if err != nil {
+			return err
+		}
+
+		req.Payloads = append(req.Payloads, payload)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encoding push request: %w", err)
+	}
+
+	var resp pushResponse
+
+	return c.do(http.MethodPost, changesPath, bytes.NewReader(body), &resp)
+}
+
+func (c *Client) pull(after int64) (pullResponse, error) {
+	query := url.Values{}
+	query.Set("after", strconv.FormatInt(after, 10))
+
+	var resp pullResponse
+	if err := c.do(http.MethodGet, changesPath+"?"+query.Encode(), nil, &resp); err != nil {
+		return pullResponse{}, err
+	}
+
+	return resp, nil
+}
+
+func (c *Client) decode(payload string) (Change, error) {
+	line, err := openPayload(c.key, payload)
+	if err != nil {
+		return Change{}, err
+	}
+
+	return DecodeChange(line)
+}
+
+func (c *Client) do(method string, path string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(context.Background(), method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("building %s request: %w", method, err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("contacting sync server: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return fmt.Errorf("%w: %s: %s", errServerStatus, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding sync server response: %w", err)
+	}
+
+	return nil
+}
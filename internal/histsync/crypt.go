@@ -0,0 +1,122 @@
+package histsync
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	keySize         = 32
+	encryptedPrefix = "enc1:"
+)
+
+var (
+	errInvalidKey       = errors.New("encryption key must be 32 bytes encoded as base64")
+	errEncryptedPayload = errors.New("change is encrypted but no encryption key is configured")
+	errPlaintextPayload = errors.New("change is not encrypted but an encryption key is configured")
+	errShortCiphertext  = errors.New("encrypted change is too short")
+)
+
+// GenerateKey returns a new random encryption key encoded as base64.
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("generating encryption key: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes a key produced by GenerateKey. An empty string disables
+// encryption and returns a nil key.
+func ParseKey(encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != keySize {
+		return nil, errInvalidKey
+	}
+
+	return key, nil
+}
+
+// sealPayload encrypts a change line with AES-256-GCM. Without a key the
+// line is sent as is.
+func sealPayload(key []byte, line []byte) (string, error) {
+	if key == nil {
+		return string(line), nil
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, line, nil)
+
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openPayload decrypts a change line. With a key, plaintext lines are
+// rejected so the server cannot inject changes of its own.
+func openPayload(key []byte, payload string) ([]byte, error) {
+	encoded, encrypted := strings.CutPrefix(payload, encryptedPrefix)
+	if !encrypted {
+		if key != nil {
+			return nil, errPlaintextPayload
+		}
+
+		return []byte(payload), nil
+	}
+
+	if key == nil {
+		return nil, errEncryptedPayload
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding encrypted change: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errShortCiphertext
+	}
+
+	line, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting change: %w", err)
+	}
+
+	return line, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating GCM: %w", err)
+	}
+
+	return aead, nil
+}
@@ -0,0 +1,244 @@
+package histsync
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const logFileExt = ".jsonl"
+
+// SyncDir exchanges history through dir, a folder shared between machines by
+// a file synchronizer or network mount. This machine appends its local
+// changes to its own log file, named after hostID, and merges the new lines
+// of every other log. Only complete lines are merged, so a log that is still
+// being transferred is picked up on a later run.
+func SyncDir(database *sql.DB, dir string, hostID string) (Summary, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Summary{}, fmt.Errorf("creating sync directory %q: %w", dir, err)
+	}
+
+	ownLog := LogFileName(hostID)
+
+	pushed, err := pushToDir(database, dir, ownLog)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary, err := pullFromDir(database, dir, ownLog)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary.Pushed = pushed
+
+	return summary, nil
+}
+
+// LogFileName returns the change log file name for hostID, replacing
+// characters that are not safe in file names.
+func LogFileName(hostID string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, hostID)
+
+	if strings.Trim(name, ".") == "" {
+		name = "unknown"
+	}
+
+	return name + logFileExt
+}
+
+func pushToDir(database *sql.DB, dir string, ownLog string) (int, error) {
+	pending, err := CollectPending(database, "dir:"+dir)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(pending.Changes) > 0 {
+		if err = appendChanges(filepath.Join(dir, ownLog), pending.Changes); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = pending.Delivered(database); err != nil {
+		return 0, err
+	}
+
+	return len(pending.Changes), nil
+}
+
+func appendChanges(path string, changes []Change) error {
+	// #nosec G304 -- path is inside the user-provided sync directory
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening change log %q: %w", path, err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	var buf bytes.Buffer
+
+	unterminated, err := endsWithoutNewline(f)
+	if err != nil {
+		return fmt.Errorf("reading change log %q: %w", path, err)
+	}
+
+	// Terminate a line left incomplete by an interrupted write so it stays
+	// separate from the changes appended now.
+	if unterminated {
+		buf.WriteByte('\n')
+	}
+
+	for _, change := range changes {
+		line, encodeErr := EncodeChange(change)
+		if encodeErr != nil {
+			return encodeErr
+		}
+
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("appending to change log %q: %w", path, err)
+	}
+
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("syncing change log %q: %w", path, err)
+	}
+
+	return nil
+}
+
+func endsWithoutNewline(f *os.File) (bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("stating file: %w", err)
+	}
+
+	if info.Size() == 0 {
+		return false, nil
+	}
+
+	last := make([]byte, 1)
+	if _, err = f.ReadAt(last, info.Size()-1); err != nil {
+		return false, fmt.Errorf("reading last byte: %w", err)
+	}
+
+	return last[0] != '\n', nil
+}
+
+func pullFromDir(database *sql.DB, dir string, ownLog string) (Summary, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return Summary{}, fmt.Errorf("listing sync directory %q: %w", dir, err)
+	}
+
+	var summary Summary
+
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if !dirEntry.Type().IsRegular() || name == ownLog || filepath.Ext(name) != logFileExt {
+			continue
+		}
+
+		merged, mergeErr := mergeLogFile(database, "dir:"+filepath.Join(dir, name), filepath.Join(dir, name))
+		if mergeErr != nil {
+			return Summary{}, mergeErr
+		}
+
+		summary.Merged += merged.Merged
+		summary.Deleted += merged.Deleted
+		summary.Sources++
+	}
+
+	return summary, nil
+}
+
+func mergeLogFile(database *sql.DB, source string, path string) (Summary, error) {
+	position, err := SourceCursor(database, source)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	changes, next, err := readChangesFrom(path, position)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	if next == position {
+		return Summary{}, nil
+	}
+
+	return Merge(database, source, changes, next)
+}
+
+// readChangesFrom returns the complete change lines after byte offset
+// position and the offset just past the last one. A log shorter than
+// position was replaced, so it is read again from the start; merging is
+// idempotent. Lines that are not valid JSON, left by interrupted writes,
+// are skipped.
+func readChangesFrom(path string, position int64) ([]Change, int64, error) {
+	// #nosec G304 -- path is inside the user-provided sync directory
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, position, fmt.Errorf("opening change log %q: %w", path, err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, position, fmt.Errorf("stating change log %q: %w", path, err)
+	}
+
+	if info.Size() < position {
+		position = 0
+	}
+
+	if _, err = f.Seek(position, io.SeekStart); err != nil {
+		return nil, position, fmt.Errorf("seeking change log %q: %w", path, err)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, position, fmt.Errorf("reading change log %q: %w", path, err)
+	}
+
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return nil, position, nil
+	}
+
+	var changes []Change
+
+	for line := range bytes.SplitSeq(data[:end], []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		change, decodeErr := DecodeChange(line)
+		if errors.Is(decodeErr, errChangeVersion) || errors.Is(decodeErr, errChangeOp) {
+			return nil, position, fmt.Errorf("reading change log %q: %w", path, decodeErr)
+		}
+
+		if decodeErr != nil {
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, position + int64(end) + 1, nil
+}
@@ -0,0 +1,164 @@
+// Package histsync replicates history between machines as per-host change
+// logs. Each host publishes only the inserts, updates and deletions made
+// locally and merges everyone else's. Merging is idempotent: entries are
+// matched by the timestamp of their first run, command, directory, session
+// and host, and deletions leave tombstones so a deleted entry is never merged
+// back.
+package histsync
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+// Summary counts the work done by one sync run.
+type Summary struct {
+	Pushed  int
+	Merged  int
+	Deleted int
+	Sources int
+}
+
+// Pending is a batch of local changes not yet delivered to a target. Call
+// Delivered once the target has durably stored them.
+type Pending struct {
+	Changes []Change
+	target  string
+	local   db.LocalChanges
+}
+
+// CollectPending returns the local changes not yet delivered to target, a
+// name identifying the sync destination.
+func CollectPending(database *sql.DB, target string) (Pending, error) {
+	var cursors db.SyncCursors
+
+	for name, cursor := range map[string]*int64{
+		historyCursorName(target):   &cursors.History,
+		updateCursorName(target):    &cursors.Update,
+		tombstoneCursorName(target): &cursors.Tombstone,
+	} {
+		position, err := db.SyncPosition(database, name)
+		if err != nil {
+			return Pending{}, err
+		}
+
+		*cursor = position
+	}
+
+	local, err := db.ReadLocalChanges(database, cursors)
+	if err != nil {
+		return Pending{}, err
+	}
+
+	changes := make([]Change, 0, len(local.Inserts)+len(local.Deletes))
+	for _, entry := range local.Inserts {
+		changes = append(changes, insertChange(entry))
+	}
+
+	for _, key := range local.Deletes {
+		changes = append(changes, deleteChange(key))
+	}
+
+	return Pending{Changes: changes, target: target, local: local}, nil
+}
+
+// Delivered advances the target's cursors past the pending changes.
+func (p Pending) Delivered(database *sql.DB) error {
+	ctx := context.Background()
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting sync cursor transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	if err = db.SetSyncPosition(tx, historyCursorName(p.target), p.local.HistoryCursor); err != nil {
+		return err
+	}
+
+	if err = db.SetSyncPosition(tx, updateCursorName(p.target), p.local.UpdateCursor); err != nil {
+		return err
+	}
+
+	if err = db.SetSyncPosition(tx, tombstoneCursorName(p.target), p.local.TombstoneCursor); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing sync cursors: %w", err)
+	}
+
+	return nil
+}
+
+// Merge applies changes received from source and stores position as the
+// source's new cursor in the same transaction, so an interrupted merge is
+// simply repeated on the next run.
+func Merge(database *sql.DB, source string, changes []Change, position int64) (Summary, error) {
+	ctx := context.Background()
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return Summary{}, fmt.Errorf("starting sync merge transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	var summary Summary
+
+	for _, change := range changes {
+		switch change.Op {
+		case OpInsert:
+			inserted, insertErr := db.ApplyRemoteInsertTx(tx, change.entry())
+			if insertErr != nil {
+				return Summary{}, insertErr
+			}
+
+			if inserted {
+				summary.Merged++
+			}
+		case OpDelete:
+			removed, deleteErr := db.ApplyRemoteDeleteTx(tx, change.entry().SyncKey())
+			if deleteErr != nil {
+				return Summary{}, deleteErr
+			}
+
+			summary.Deleted += int(removed)
+		}
+	}
+
+	if err = db.SetSyncPosition(tx, sourceCursorName(source), position); err != nil {
+		return Summary{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Summary{}, fmt.Errorf("committing sync merge: %w", err)
+	}
+
+	return summary, nil
+}
+
+// SourceCursor returns how far the changes from source have been merged.
+func SourceCursor(database *sql.DB, source string) (int64, error) {
+	return db.SyncPosition(database, sourceCursorName(source))
+}
+
+func sourceCursorName(source string) string {
+	return "source:" + source
+}
+
+func historyCursorName(target string) string {
+	return "target:" + target + ":history"
+}
+
+func updateCursorName(target string) string {
+	return "target:" + target + ":updates"
+}
+
+func tombstoneCursorName(target string) string {
+	return "target:" + target + ":tombstones"
+}
@@ -0,0 +1,251 @@
+package histsync
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+func openTestDB(t *testing.T) (*sql.DB, *db.HistoryRepo) {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	t.Cleanup(func() { _ = database.Close() })
+
+	return database, db.NewHistoryRepo(database)
+}
+
+func insertCommands(t *testing.T, repo *db.HistoryRepo, hostname string, commands ...string) {
+	t.Helper()
+
+	for i, command := range commands {
+		entry := db.HistoryEntry{TsMs: int64(1000 + i), Command: command, Hostname: hostname, SessionID: hostname}
+		if _, err := repo.Insert(entry); err != nil {
+			t.Fatalf("Insert(%q) error: %v", command, err)
+		}
+	}
+}
+
+func commands(t *testing.T, repo *db.HistoryRepo) []string {
+	t.Helper()
+
+	entries, err := repo.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	result := make([]string, len(entries))
+	for i, e := range entries {
+		result[i] = e.Command
+	}
+
+	slices.Sort(result)
+
+	return result
+}
+
+func syncDir(t *testing.T, database *sql.DB, dir string, hostID string) Summary {
+	t.Helper()
+
+	summary, err := SyncDir(database, dir, hostID)
+	if err != nil {
+		t.Fatalf("SyncDir(%s) error: %v", hostID, err)
+	}
+
+	return summary
+}
+
+func TestSyncDirReplicatesInsertsAndDeletions(t *testing.T) {
+	dir := t.TempDir()
+	dbA, repoA := openTestDB(t)
+	dbB, repoB := openTestDB(t)
+
+	insertCommands(t, repoA, "a", "make build", "git status")
+	syncDir(t, dbA, dir, "a")
+
+	summary := syncDir(t, dbB, dir, "b")
+	if summary.Merged != 2 || summary.Sources != 1 {
+		t.Fatalf("first B sync = %+v, want merged=2 sources=1", summary)
+	}
+
+	if summary = syncDir(t, dbB, dir, "b"); summary.Merged != 0 || summary.Pushed != 0 {
+		t.Fatalf("repeated B sync = %+v, want nothing to do", summary)
+	}
+
+	insertCommands(t, repoB, "b", "ls")
+
+	entries, err := repoB.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	for _, e := range entries {
+		if e.Command == "make build" {
+			if err = repoB.Delete(e.ID); err != nil {
+				t.Fatalf("Delete() error: %v", err)
+			}
+		}
+	}
+
+	if summary = syncDir(t, dbB, dir, "b"); summary.Pushed != 2 {
+		t.Fatalf("B sync after changes = %+v, want pushed=2 (one insert, one deletion)", summary)
+	}
+
+	if summary = syncDir(t, dbA, dir, "a"); summary.Merged != 1 || summary.Deleted != 1 {
+		t.Fatalf("A sync = %+v, want merged=1 deleted=1", summary)
+	}
+
+	want := []string{"git status", "ls"}
+	if got := commands(t, repoA); !slices.Equal(got, want) {
+		t.Fatalf("A commands = %v, want %v", got, want)
+	}
+
+	if got := commands(t, repoB); !slices.Equal(got, want) {
+		t.Fatalf("B commands = %v, want %v", got, want)
+	}
+
+	// A's log only carries A's own changes, never the ones merged from B.
+	logA, err := os.ReadFile(filepath.Join(dir, LogFileName("a")))
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+
+	if lines := strings.Count(string(logA), "\n"); lines != 2 {
+		t.Fatalf("A log has %d lines, want 2:\n%s", lines, logA)
+	}
+}
+
+func TestSyncDirWaitsForIncompleteLines(t *testing.T) {
+	dir := t.TempDir()
+	database, repo := openTestDB(t)
+
+	line, err := EncodeChange(insertChange(db.HistoryEntry{TsMs: 5000, Command: "echo remote", Hostname: "c", RunCount: 1}))
+	if err != nil {
+		t.Fatalf("EncodeChange() error: %v", err)
+	}
+
+	logPath := filepath.Join(dir, LogFileName("c"))
+	if err = os.WriteFile(logPath, line[:len(line)/2], 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	if summary := syncDir(t, database, dir, "local"); summary.Merged != 0 {
+		t.Fatalf("sync with partial line = %+v, want merged=0", summary)
+	}
+
+	if err = os.WriteFile(logPath, append(line, '\n'), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	if summary := syncDir(t, database, dir, "local"); summary.Merged != 1 {
+		t.Fatalf("sync with complete line = %+v, want merged=1", summary)
+	}
+
+	if got := commands(t, repo); !slices.Equal(got, []string{"echo remote"}) {
+		t.Fatalf("commands = %v, want [echo remote]", got)
+	}
+}
+
+func TestLogFileNameReplacesUnsafeCharacters(t *testing.T) {
+	if got := LogFileName("my host/../x"); got != "my_host_.._x.jsonl" {
+		t.Fatalf("LogFileName() = %q", got)
+	}
+
+	if got := LogFileName(".."); got != "unknown.jsonl" {
+		t.Fatalf("LogFileName(..) = %q, want unknown.jsonl", got)
+	}
+}
+
+func onlyEntry(t *testing.T, repo *db.HistoryRepo) db.HistoryEntry {
+	t.Helper()
+
+	entries, err := repo.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("entries = %+v, want exactly one", entries)
+	}
+
+	return entries[0]
+}
+
+func TestSyncDirReplicatesCollapsedRuns(t *testing.T) {
+	dir := t.TempDir()
+	dbA, repoA := openTestDB(t)
+	dbB, repoB := openTestDB(t)
+
+	entry := db.HistoryEntry{TsMs: 1000, Command: "make", SessionID: "a", Hostname: "a"}
+	if _, err := repoA.InsertCollapsed(entry); err != nil {
+		t.Fatalf("InsertCollapsed() error: %v", err)
+	}
+
+	syncDir(t, dbA, dir, "a")
+	syncDir(t, dbB, dir, "b")
+
+	for _, ts := range []int64{2000, 3000, 4000} {
+		entry.TsMs = ts
+		if _, err := repoA.InsertCollapsed(entry); err != nil {
+			t.Fatalf("InsertCollapsed() error: %v", err)
+		}
+	}
+
+	syncDir(t, dbA, dir, "a")
+	syncDir(t, dbB, dir, "b")
+
+	if got := onlyEntry(t, repoB); got.TsMs != 4000 || got.RunCount != 4 {
+		t.Fatalf("B entry = %+v, want the collapsed run at 4000 with 4 runs", got)
+	}
+
+	// The repeats reach the log as one update of the original entry.
+	logA, err := os.ReadFile(filepath.Join(dir, LogFileName("a")))
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+
+	if lines := strings.Count(string(logA), "\n"); lines != 2 || strings.Contains(string(logA), `"delete"`) {
+		t.Fatalf("A log = %s, want the insert and one update", logA)
+	}
+
+	if err := repoA.Delete(onlyEntry(t, repoA).ID); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+
+	syncDir(t, dbA, dir, "a")
+	syncDir(t, dbB, dir, "b")
+
+	if got := commands(t, repoB); len(got) != 0 {
+		t.Fatalf("B commands after delete = %v, want none", got)
+	}
+}
+
+func TestSyncDirReplicatesCompaction(t *testing.T) {
+	dir := t.TempDir()
+	dbA, repoA := openTestDB(t)
+	dbB, repoB := openTestDB(t)
+
+	insertCommands(t, repoA, "a", "make", "make")
+	syncDir(t, dbA, dir, "a")
+	syncDir(t, dbB, dir, "b")
+
+	if removed, err := repoA.CompactDuplicates(); err != nil || removed != 1 {
+		t.Fatalf("CompactDuplicates() = %d, %v, want 1 removed", removed, err)
+	}
+
+	syncDir(t, dbA, dir, "a")
+	syncDir(t, dbB, dir, "b")
+
+	if got := onlyEntry(t, repoB); got.TsMs != 1001 || got.RunCount != 2 {
+		t.Fatalf("B entry = %+v, want the compacted run at 1001 with 2 runs", got)
+	}
+}
@@ -0,0 +1,196 @@
+package histsync
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+const (
+	changesPath      = "/v1/changes"
+	tokenSize        = 32
+	maxPushBodyBytes = 32 << 20
+	defaultPullLimit = 1000
+	maxPullLimit     = 5000
+)
+
+var errUnauthorized = errors.New("missing or unknown sync token")
+
+// pushRequest uploads a batch of change payloads. A payload is a change log
+// line, or its ciphertext when the client encrypts.
+type pushRequest struct {
+	Payloads []string `json:"payloads"`
+}
+
+type pushResponse struct {
+	Stored int `json:"stored"`
+}
+
+type pulledChange struct {
+	Seq     int64  `json:"seq"`
+	Payload string `json:"payload"`
+}
+
+// pullResponse returns changes after the requested cursor. Next is the
+// cursor for the following request and More reports whether it has more.
+type pullResponse struct {
+	Changes []pulledChange `json:"changes"`
+	Next    int64          `json:"next"`
+	More    bool           `json:"more"`
+}
+
+// Server is the HTTP side of server sync. Each host authenticates with its
+// own bearer token, uploads its local changes and downloads everyone else's.
+// Payloads are stored as received, so with client-side encryption the server
+// never sees commands.
+type Server struct {
+	store *db.SyncStore
+}
+
+func NewServer(store *db.SyncStore) *Server {
+	return &Server{store: store}
+}
+
+// IssueToken creates a new access token for host, replacing its previous
+// token. Only the token's hash is stored.
+func IssueToken(store *db.SyncStore, host string) (string, error) {
+	raw := make([]byte, tokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+
+	token := hex.EncodeToString(raw)
+	if err := store.SetHostToken(host, hashToken(token)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+changesPath, s.handlePush)
+	mux.HandleFunc("GET "+changesPath, s.handlePull)
+
+	return mux
+}
+
+func (s *Server) authenticate(r *http.Request) (string, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", errUnauthorized
+	}
+
+	host, found, err := s.store.HostForToken(hashToken(token))
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		return "", errUnauthorized
+	}
+
+	return host, nil
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	host, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var req pushRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushBodyBytes)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.Append(host, req.Payloads); err != nil {
+		http.Error(w, "storing changes failed", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, pushResponse{Stored: len(req.Payloads)})
+}
+
+func (s *Server) handlePull(w http.ResponseWriter, r *http.Request) {
+	host, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	after, err := queryInt(r, "after", 0)
+	if err != nil || after < 0 {
+		http.Error(w, "invalid after cursor", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultPullLimit)
+	if err != nil || limit < 1 {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	limit = min(limit, maxPullLimit)
+
+	stored, err := s.store.ChangesAfter(after, host, int(limit)+1)
+	if err != nil {
+		http.Error(w, "reading changes failed", http.StatusInternalServerError)
+		return
+	}
+
+	resp := pullResponse{Changes: []pulledChange{}, Next: after, More: len(stored) > int(limit)}
+	for _, change := range stored[:min(len(stored), int(limit))] {
+		resp.Changes = append(resp.Changes, pulledChange{Seq: change.Seq, Payload: change.Payload})
+		resp.Next = change.Seq
+	}
+
+	writeJSON(w, resp)
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) (string, bool) {
+	host, err := s.authenticate(r)
+	if errors.Is(err, errUnauthorized) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return "", false
+	}
+
+	if err != nil {
+		http.Error(w, "authentication failed", http.StatusInternalServerError)
+		return "", false
+	}
+
+	return host, true
+}
+
+func queryInt(r *http.Request, name string, fallback int64) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", name, err)
+	}
+
+	return parsed, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
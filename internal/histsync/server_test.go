@@ -0,0 +1,199 @@
+package histsync
+
+import (
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/zigai/zgod/internal/db"
+)
+
+func startTestServer(t *testing.T) (*httptest.Server, *db.SyncStore) {
+	t.Helper()
+
+	store, err := db.OpenSyncStore(filepath.Join(t.TempDir(), "sync-server.db"))
+	if err != nil {
+		t.Fatalf("OpenSyncStore() error: %v", err)
+	}
+
+	t.Cleanup(func() { _ = store.Close() })
+
+	server := httptest.NewServer(NewServer(store).Handler())
+	t.Cleanup(server.Close)
+
+	return server, store
+}
+
+func newTestClient(t *testing.T, server *httptest.Server, store *db.SyncStore, host string, key []byte) *Client {
+	t.Helper()
+
+	token, err := IssueToken(store, host)
+	if err != nil {
+		t.Fatalf("IssueToken(%s) error: %v", host, err)
+	}
+
+	return NewClient(server.URL+"/", token, key)
+}
+
+func syncServer(t *testing.T, database *sql.DB, client *Client) Summary {
+	t.Helper()
+
+	summary, err := SyncServer(database, client)
+	if err != nil {
+		t.Fatalf("SyncServer() error: %v", err)
+	}
+
+	return summary
+}
+
+func TestSyncServerReplicatesEncryptedChanges(t *testing.T) {
+	server, store := startTestServer(t)
+
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+
+	key, err := ParseKey(encoded)
+	if err != nil {
+		t.Fatalf("ParseKey() error: %v", err)
+	}
+
+	dbA, repoA := openTestDB(t)
+	dbB, repoB := openTestDB(t)
+	clientA := newTestClient(t, server, store, "a", key)
+	clientB := newTestClient(t, server, store, "b", key)
+
+	insertCommands(t, repoA, "a", "export SECRET=hunter2", "git status")
+
+	if summary := syncServer(t, dbA, clientA); summary.Pushed != 2 || summary.Merged != 0 {
+		t.Fatalf("first A sync = %+v, want pushed=2 merged=0", summary)
+	}
+
+	if summary := syncServer(t, dbB, clientB); summary.Merged != 2 || summary.Pushed != 0 {
+		t.Fatalf("first B sync = %+v, want merged=2 pushed=0", summary)
+	}
+
+	stored, err := store.ChangesAfter(0, "", 100)
+	if err != nil {
+		t.Fatalf("ChangesAfter() error: %v", err)
+	}
+
+	for _, change := range stored {
+		if strings.Contains(change.Payload, "hunter2") || !strings.HasPrefix(change.Payload, encryptedPrefix) {
+			t.Fatalf("stored payload is not encrypted: %q", change.Payload)
+		}
+	}
+
+	entries, err := repoB.ListAll()
+	if err != nil {
+		t.Fatalf("ListAll() error: %v", err)
+	}
+
+	for _, e := range entries {
+		if e.Command == "git status" {
+			if err = repoB.Delete(e.ID); err != nil {
+				t.Fatalf("Delete() error: %v", err)
+			}
+		}
+	}
+
+	if summary := syncServer(t, dbB, clientB); summary.Pushed != 1 {
+		t.Fatalf("B sync after delete = %+v, want pushed=1", summary)
+	}
+
+	if summary := syncServer(t, dbA, clientA); summary.Deleted != 1 || summary.Pushed != 0 {
+		t.Fatalf("A sync = %+v, want deleted=1 pushed=0", summary)
+	}
+
+	want := []string{"export SECRET=hunter2"}
+	if got := commands(t, repoA); !slices.Equal(got, want) {
+		t.Fatalf("A commands = %v, want %v", got, want)
+	}
+
+	if got := commands(t, repoB); !slices.Equal(got, want) {
+		t.Fatalf("B commands = %v, want %v", got, want)
+	}
+}
+
+func TestSyncServerPagesThroughLargeBacklogs(t *testing.T) {
+	server, store := startTestServer(t)
+	dbA, repoA := openTestDB(t)
+	dbB, repoB := openTestDB(t)
+
+	batch := make([]string, defaultPullLimit+5)
+	for i := range batch {
+		batch[i] = "echo " + strconv.Itoa(i)
+	}
+
+	insertCommands(t, repoA, "a", batch...)
+	syncServer(t, dbA, newTestClient(t, server, store, "a", nil))
+
+	if summary := syncServer(t, dbB, newTestClient(t, server, store, "b", nil)); summary.Merged != len(batch) {
+		t.Fatalf("B sync = %+v, want merged=%d", summary, len(batch))
+	}
+
+	if got := len(commands(t, repoB)); got != len(batch) {
+		t.Fatalf("B has %d commands, want %d", got, len(batch))
+	}
+}
+
+func TestSyncServerRejectsUnknownToken(t *testing.T) {
+	server, _ := startTestServer(t)
+	database, _ := openTestDB(t)
+
+	_, err := SyncServer(database, NewClient(server.URL, "not-a-token", nil))
+	if !errors.Is(err, errServerStatus) || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("SyncServer() error = %v, want 401 from server", err)
+	}
+}
+
+func TestSyncServerEncryptingClientRejectsPlaintextChanges(t *testing.T) {
+	server, store := startTestServer(t)
+
+	key, err := ParseKey(strings.Repeat("A", 43) + "=")
+	if err != nil {
+		t.Fatalf("ParseKey() error: %v", err)
+	}
+
+	dbA, repoA := openTestDB(t)
+	dbB, repoB := openTestDB(t)
+
+	insertCommands(t, repoA, "a", "rm -rf ~")
+	syncServer(t, dbA, newTestClient(t, server, store, "a", nil))
+
+	_, err = SyncServer(dbB, newTestClient(t, server, store, "b", key))
+	if !errors.Is(err, errPlaintextPayload) {
+		t.Fatalf("SyncServer() error = %v, want %v", err, errPlaintextPayload)
+	}
+
+	if got := commands(t, repoB); len(got) != 0 {
+		t.Fatalf("B commands = %v, want none", got)
+	}
+}
+
+func TestOpenPayloadWithoutKeyRejectsEncryptedChanges(t *testing.T) {
+	key, err := ParseKey(strings.Repeat("A", 43) + "=")
+	if err != nil {
+		t.Fatalf("ParseKey() error: %v", err)
+	}
+
+	payload, err := sealPayload(key, []byte(`{"v":1}`))
+	if err != nil {
+		t.Fatalf("sealPayload() error: %v", err)
+	}
+
+	if _, err = openPayload(nil, payload); !errors.Is(err, errEncryptedPayload) {
+		t.Fatalf("openPayload(nil) error = %v, want %v", err, errEncryptedPayload)
+	}
+
+	line, err := openPayload(key, payload)
+	if err != nil || string(line) != `{"v":1}` {
+		t.Fatalf("openPayload() = %q, %v", line, err)
+	}
+}
@@ -0,0 +1,93 @@
+package match
+
+import "unicode"
+
+// CaseMode controls case sensitivity for every match mode.
+type CaseMode int
+
+const (
+	// CaseSmart is case-insensitive until the query contains an uppercase
+	// letter.
+	CaseSmart CaseMode = iota
+	CaseInsensitive
+	CaseSensitive
+)
+
+func (c CaseMode) String() string {
+	switch c {
+	case CaseSmart:
+		return "smart"
+	case CaseInsensitive:
+		return "insensitive"
+	case CaseSensitive:
+		return "sensitive"
+	}
+
+	return "smart"
+}
+
+func ParseCaseMode(s string) (CaseMode, bool) {
+	switch s {
+	case "", "smart":
+		return CaseSmart, true
+	case "insensitive":
+		return CaseInsensitive, true
+	case "sensitive":
+		return CaseSensitive, true
+	default:
+		return CaseSmart, false
+	}
+}
+
+func (c CaseMode) Next() CaseMode {
+	switch c {
+	case CaseSmart:
+		return CaseSensitive
+	case CaseSensitive:
+		return CaseInsensitive
+	case CaseInsensitive:
+		return CaseSmart
+	}
+
+	return CaseSmart
+}
+
+// Sensitive reports whether pattern is matched case-sensitively.
+func (c CaseMode) Sensitive(pattern string) bool {
+	switch c {
+	case CaseSensitive:
+		return true
+	case CaseInsensitive:
+		return false
+	case CaseSmart:
+	}
+
+	return hasUpper(pattern)
+}
+
+// hasUpper reports whether s contains an uppercase letter outside of a
+// backslash escape, so regex classes like \S do not turn on smart case.
+func hasUpper(s string) bool {
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case unicode.IsUpper(r):
+			return true
+		}
+	}
+
+	return false
+}
+
+// foldRunes returns s as runes, lowercased unless sensitive.
+func foldRunes(s string, sensitive bool) []rune {
+	if sensitive {
+		return []rune(s)
+	}
+
+	return lowerRunes(s)
+}
@@ -0,0 +1,268 @@
+package match
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const exactTermScore = 100
+
+type termKind int
+
+const (
+	termFuzzy termKind = iota
+	termExact
+	termPrefix
+	termSuffix
+	termEqual
+)
+
+// term is one space-separated word of an extended query. text is lowercased
+// unless matching is case-sensitive.
+type term struct {
+	kind   termKind
+	text   []rune
+	raw    string
+	negate bool
+}
+
+// termGroup holds terms joined with "|"; a candidate satisfies the group if
+// any of them matches.
+type termGroup []term
+
+// parseQuery splits an fzf-style query into groups that must all match:
+//
+//	foo     fuzzy match
+//	'foo    exact substring
+//	^foo    prefix
+//	foo$    suffix
+//	!foo    does not contain foo (also !^foo, !foo$)
+//	a | b   a or b
+func parseQuery(pattern string, sensitive bool) []termGroup {
+	var (
+		groups []termGroup
+		joinOr bool
+	)
+
+	for _, token := range strings.Fields(pattern) {
+		if token == "|" {
+			joinOr = len(groups) > 0
+
+			continue
+		}
+
+		t, ok := parseTerm(token, sensitive)
+		if !ok {
+			continue
+		}
+
+		if joinOr {
+			groups[len(groups)-1] = append(groups[len(groups)-1], t)
+		} else {
+			groups = append(groups, termGroup{t})
+		}
+
+		joinOr = false
+	}
+
+	return groups
+}
+
+func parseTerm(token string, sensitive bool) (term, bool) {
+	t := term{kind: termFuzzy, text: nil, raw: "", negate: false}
+
+	if rest, ok := strings.CutPrefix(token, "!"); ok {
+		t.negate = true
+		t.kind = termExact
+		token = rest
+	}
+
+	switch {
+	case strings.HasPrefix(token, "'"):
+		t.kind = termExact
+		token = token[1:]
+	case strings.HasPrefix(token, "^"):
+		t.kind = termPrefix
+		token = token[1:]
+	}
+
+	if rest, ok := strings.CutSuffix(token, "$"); ok && rest != "" {
+		if t.kind == termPrefix {
+			t.kind = termEqual
+		} else {
+			t.kind = termSuffix
+		}
+
+		token = rest
+	}
+
+	if token == "" {
+		return term{}, false
+	}
+
+	t.raw = token
+	t.text = foldRunes(token, sensitive)
+
+	return t, true
+}
+
+func isPlainFuzzy(groups []termGroup) bool {
+	return len(groups) == 1 && len(groups[0]) == 1 && groups[0][0].kind == termFuzzy && !groups[0][0].negate
+}
+
+// matchExtended runs every term against the candidates and keeps those that
+// satisfy all groups. Scores are summed and highlighted ranges merged.
+func matchExtended(find fuzzyFinder, groups []termGroup, candidates []string, sensitive bool) []Match {
+	fuzzyResults := make(map[string]map[int]Match)
+
+	for _, group := range groups {
+		for _, t := range group {
+			if t.kind != termFuzzy || fuzzyResults[t.raw] != nil {
+				continue
+			}
+
+			byIndex := make(map[int]Match)
+			for _, m := range fuzzyTerm(find, t.raw, candidates, sensitive) {
+				byIndex[m.Index] = m
+			}
+
+			fuzzyResults[t.raw] = byIndex
+		}
+	}
+
+	var matches []Match
+
+	for i, candidate := range candidates {
+		folded := foldRunes(candidate, sensitive)
+		total := Match{Index: i, Score: 0, MatchedRanges: nil}
+		ok := true
+
+		for _, group := range groups {
+			score, ranges, matched := matchGroup(group, i, folded, fuzzyResults)
+			if !matched {
+				ok = false
+
+				break
+			}
+
+			total.Score += score
+			total.MatchedRanges = append(total.MatchedRanges, ranges...)
+		}
+
+		if !ok {
+			continue
+		}
+
+		total.MatchedRanges = mergeRanges(total.MatchedRanges)
+		matches = append(matches, total)
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].Score > matches[b].Score
+	})
+
+	return matches
+}
+
+func matchGroup(group termGroup, index int, candidate []rune, fuzzyResults map[string]map[int]Match) (int, []Range, bool) {
+	for _, t := range group {
+		if t.kind == termFuzzy {
+			if m, ok := fuzzyResults[t.raw][index]; ok {
+				return m.Score, m.MatchedRanges, true
+			}
+
+			continue
+		}
+
+		r, found := t.find(candidate)
+		if t.negate {
+			if !found {
+				return 0, nil, true
+			}
+
+			continue
+		}
+
+		if found {
+			return exactTermScore, []Range{r}, true
+		}
+	}
+
+	return 0, nil, false
+}
+
+func (t term) find(candidate []rune) (Range, bool) {
+	n := len(t.text)
+
+	switch t.kind {
+	case termPrefix:
+		if hasRunePrefix(candidate, t.text) {
+			return Range{Start: 0, End: n}, true
+		}
+	case termSuffix:
+		if len(candidate) >= n && hasRunePrefix(candidate[len(candidate)-n:], t.text) {
+			return Range{Start: len(candidate) - n, End: len(candidate)}, true
+		}
+	case termEqual:
+		if len(candidate) == n && hasRunePrefix(candidate, t.text) {
+			return Range{Start: 0, End: n}, true
+		}
+	case termExact, termFuzzy:
+		for start := 0; start+n <= len(candidate); start++ {
+			if hasRunePrefix(candidate[start:], t.text) {
+				return Range{Start: start, End: start + n}, true
+			}
+		}
+	}
+
+	return Range{}, false
+}
+
+func hasRunePrefix(s []rune, prefix []rune) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+
+	return true
+}
+
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+
+	return runes
+}
+
+// mergeRanges sorts ranges and joins the ones that overlap or touch.
+func mergeRanges(ranges []Range) []Range {
+	if len(ranges) < 2 {
+		return ranges
+	}
+
+	sort.Slice(ranges, func(a, b int) bool {
+		return ranges[a].Start < ranges[b].Start
+	})
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			last.End = max(last.End, r.End)
+
+			continue
+		}
+
+		merged = append(merged, r)
+	}
+
+	return merged
+}
@@ -6,21 +6,120 @@ import (
 	"github.com/sahilm/fuzzy"
 )
 
-type FuzzyMatcher struct{}
+// FuzzyAlgorithm selects the scorer behind fuzzy terms.
+type FuzzyAlgorithm int
+
+const (
+	// FuzzyClassic scores with sahilm/fuzzy.
+	FuzzyClassic FuzzyAlgorithm = iota
+	// FuzzyV2 scores with boundary, adjacency and gap rules modeled on fzf.
+	FuzzyV2
+)
+
+func (a FuzzyAlgorithm) String() string {
+	switch a {
+	case FuzzyClassic:
+		return "classic"
+	case FuzzyV2:
+		return "v2"
+	}
+
+	return "classic"
+}
+
+func ParseFuzzyAlgorithm(s string) (FuzzyAlgorithm, bool) {
+	switch s {
+	case "", "classic":
+		return FuzzyClassic, true
+	case "v2":
+		return FuzzyV2, true
+	default:
+		return FuzzyClassic, false
+	}
+}
+
+// fuzzyFinder matches one fuzzy pattern against every candidate.
+type fuzzyFinder func(pattern string, candidates []string, sensitive bool) []Match
+
+func (a FuzzyAlgorithm) finder() fuzzyFinder {
+	if a == FuzzyV2 {
+		return fuzzyV2Find
+	}
+
+	return fuzzyFind
+}
+
+// FuzzyMatcher matches with fzf-style extended syntax: space-separated terms
+// are ANDed, and each term is fuzzy unless marked with ', ^, $ or !.
+type FuzzyMatcher struct {
+	Case      CaseMode
+	Algorithm FuzzyAlgorithm
+}
 
 func (m *FuzzyMatcher) Match(pattern string, candidates []string) []Match {
+	sensitive := m.Case.Sensitive(pattern)
+	find := m.Algorithm.finder()
+
+	groups := parseQuery(pattern, sensitive)
+	if len(groups) == 0 {
+		return find(pattern, candidates, sensitive)
+	}
+
+	if isPlainFuzzy(groups) {
+		return fuzzyTerm(find, groups[0][0].raw, candidates, sensitive)
+	}
+
+	return matchExtended(find, groups, candidates, sensitive)
+}
+
+// fuzzyTerm matches one fuzzy term, preferring initialism and word-prefix
+// matches over scattered fuzzy hits.
+func fuzzyTerm(find fuzzyFinder, pattern string, candidates []string, sensitive bool) []Match {
+	matches := find(pattern, candidates, sensitive)
+
+	byIndex := make(map[int]int, len(matches))
+	for i, m := range matches {
+		byIndex[m.Index] = i
+	}
+
+	for _, m := range matchInitialism(pattern, candidates, sensitive) {
+		i, ok := byIndex[m.Index]
+		if !ok {
+			matches = append(matches, m)
+
+			continue
+		}
+
+		if m.Score > matches[i].Score {
+			matches[i] = m
+		}
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].Score > matches[b].Score
+	})
+
+	return matches
+}
+
+// fuzzyFind runs sahilm/fuzzy, which ignores case. When sensitive, results
+// whose pattern does not occur in order with matching case are dropped and
+// the rest are highlighted where the case matches.
+func fuzzyFind(pattern string, candidates []string, sensitive bool) []Match {
 	results := fuzzy.Find(pattern, candidates)
 	sort.Stable(results)
 
-	matches := make([]Match, len(results))
-	for i, r := range results {
+	matches := make([]Match, 0, len(results))
+	for _, r := range results {
 		ranges := make([]Range, 0)
 
 		if len(r.MatchedIndexes) > 0 {
-			start := r.MatchedIndexes[0]
+			runeStarts := buildRuneByteOffsets(r.Str)
+			start := byteOffsetToRuneIndex(runeStarts, r.MatchedIndexes[0])
 
 			end := start + 1
-			for _, idx := range r.MatchedIndexes[1:] {
+			for _, byteIdx := range r.MatchedIndexes[1:] {
+				idx := byteOffsetToRuneIndex(runeStarts, byteIdx)
 				if idx == end {
 					end++
 				} else {
@@ -33,12 +132,55 @@ func (m *FuzzyMatcher) Match(pattern string, candidates []string) []Match {
 			ranges = append(ranges, Range{Start: start, End: end})
 		}
 
-		matches[i] = Match{
+		if sensitive {
+			var ok bool
+			if ranges, ok = caseSensitiveRanges(pattern, r.Str, ranges); !ok {
+				continue
+			}
+		}
+
+		matches = append(matches, Match{
 			Index:         r.Index,
 			Score:         r.Score,
 			MatchedRanges: ranges,
-		}
+		})
 	}
 
 	return matches
 }
+
+// caseSensitiveRanges keeps ranges if the characters they cover spell
+// pattern exactly. Otherwise it finds the leftmost case-exact subsequence,
+// reporting false when there is none.
+func caseSensitiveRanges(pattern string, candidate string, ranges []Range) ([]Range, bool) {
+	want := []rune(pattern)
+	runes := []rune(candidate)
+
+	var covered []rune
+	for _, r := range ranges {
+		covered = append(covered, runes[r.Start:r.End]...)
+	}
+
+	if string(covered) == pattern {
+		return ranges, true
+	}
+
+	var exact []Range
+
+	next := 0
+	for i := 0; i < len(runes) && next < len(want); i++ {
+		if runes[i] != want[next] {
+			continue
+		}
+
+		if n := len(exact); n > 0 && exact[n-1].End == i {
+			exact[n-1].End++
+		} else {
+			exact = append(exact, Range{Start: i, End: i + 1})
+		}
+
+		next++
+	}
+
+	return exact, next == len(want)
+}
@@ -0,0 +1,358 @@
+package match
+
+import (
+	"sort"
+	"unicode"
+)
+
+// Scoring constants follow fzf's v2 algorithm: matches earn points, gaps
+// cost more to open than to extend, and characters at word boundaries,
+// camelCase humps or after path and flag separators earn bonuses that runs
+// of consecutive matches inherit.
+const (
+	v2ScoreMatch        = 16
+	v2ScoreGapStart     = -3
+	v2ScoreGapExtension = -1
+
+	v2BonusBoundary          = v2ScoreMatch / 2
+	v2BonusBoundaryWhite     = v2BonusBoundary + 2
+	v2BonusBoundaryDelimiter = v2BonusBoundary + 1
+	v2BonusNonWord           = v2ScoreMatch / 2
+	v2BonusCamel123          = v2BonusBoundary + v2ScoreGapExtension
+	v2BonusConsecutive       = -(v2ScoreGapStart + v2ScoreGapExtension)
+	v2BonusFirstCharFactor   = 2
+
+	// v2MaxMatrix caps the cells of the score matrix. Longer candidates are
+	// scored on a greedy alignment instead, as fzf falls back to v1.
+	v2MaxMatrix = 100 * 1024
+)
+
+type charClass int
+
+const (
+	charWhite charClass = iota
+	charNonWord
+	charDelimiter
+	charLower
+	charUpper
+	charLetter
+	charNumber
+)
+
+func classOf(r rune) charClass {
+	switch {
+	case unicode.IsSpace(r):
+		return charWhite
+	case r == '/' || r == ',' || r == ':' || r == ';' || r == '|':
+		return charDelimiter
+	case unicode.IsLower(r):
+		return charLower
+	case unicode.IsUpper(r):
+		return charUpper
+	case unicode.IsLetter(r):
+		return charLetter
+	case unicode.IsNumber(r):
+		return charNumber
+	default:
+		return charNonWord
+	}
+}
+
+func boundaryBonus(prev charClass, cur charClass) int {
+	if cur > charDelimiter {
+		switch prev {
+		case charWhite:
+			return v2BonusBoundaryWhite
+		case charDelimiter:
+			return v2BonusBoundaryDelimiter
+		case charNonWord:
+			return v2BonusBoundary
+		case charLower, charUpper, charLetter, charNumber:
+		}
+	}
+
+	if prev == charLower && cur == charUpper || prev != charNumber && cur == charNumber {
+		return v2BonusCamel123
+	}
+
+	switch cur {
+	case charNonWord, charDelimiter:
+		return v2BonusNonWord
+	case charWhite:
+		return v2BonusBoundaryWhite
+	case charLower, charUpper, charLetter, charNumber:
+	}
+
+	return 0
+}
+
+// fuzzyV2 scores pattern against text with fzf's v2 dynamic programming
+// and returns the positions of the optimal alignment. pattern and folded
+// must already be case-folded alike; original supplies character classes.
+type fuzzyV2 struct {
+	scores      []int
+	consecutive []int
+	bonus       []int
+	first       []int
+}
+
+func (f *fuzzyV2) match(pattern []rune, folded []rune, original []rune) (int, []int, bool) {
+	m, n := len(pattern), len(folded)
+	if m == 0 || m > n {
+		return 0, nil, false
+	}
+
+	// first[i] is the earliest position pattern[i] can occupy; it also
+	// rejects texts that do not contain pattern as a subsequence.
+	f.first = f.first[:0]
+	for i, j := 0, 0; i < m; i, j = i+1, j+1 {
+		for j < n && folded[j] != pattern[i] {
+			j++
+		}
+
+		if j == n {
+			return 0, nil, false
+		}
+
+		f.first = append(f.first, j)
+	}
+
+	f.bonus = f.bonus[:0]
+
+	prev := charWhite
+	for _, r := range original {
+		cur := classOf(r)
+		f.bonus = append(f.bonus, boundaryBonus(prev, cur))
+		prev = cur
+	}
+
+	size := m * n
+	if size > v2MaxMatrix {
+		return f.greedy(pattern, folded)
+	}
+
+	if cap(f.scores) < size {
+		f.scores = make([]int, size)
+		f.consecutive = make([]int, size)
+	}
+
+	H, C := f.scores[:size], f.consecutive[:size]
+	clear(H)
+	clear(C)
+
+	bestScore, bestPos := -1, -1
+
+	for i := range m {
+		inGap := false
+		row := i * n
+
+		for j := f.first[i]; j < n; j++ {
+			left := 0
+			if j > f.first[i] {
+				left = H[row+j-1]
+			}
+
+			gap := left + v2ScoreGapStart
+			if inGap {
+				gap = left + v2ScoreGapExtension
+			}
+
+			score, run := 0, 0
+
+			if folded[j] == pattern[i] {
+				if i == 0 {
+					score = v2ScoreMatch + f.bonus[j]*v2BonusFirstCharFactor
+					run = 1
+				} else {
+					score, run = f.extend(H[row-n+j-1]+v2ScoreMatch, C[row-n+j-1]+1, j, gap)
+				}
+			}
+
+			if i == 0 && run == 1 {
+				H[row+j] = score
+				inGap = false
+			} else {
+				inGap = score < gap
+				H[row+j] = max(score, gap, 0)
+			}
+
+			if H[row+j] != score || score == 0 {
+				run = 0
+			}
+
+			C[row+j] = run
+
+			if i == m-1 && run > 0 && score > bestScore {
+				bestScore, bestPos = score, j
+			}
+		}
+	}
+
+	if bestPos < 0 {
+		return 0, nil, false
+	}
+
+	return bestScore, f.backtrack(m, n, bestPos), true
+}
+
+// extend scores a match at j that continues the alignment on the diagonal,
+// letting consecutive matches carry the bonus of the run's first character.
+func (f *fuzzyV2) extend(diag int, run int, j int, gap int) (int, int) {
+	bonus := f.bonus[j]
+
+	if run > 1 {
+		firstBonus := f.bonus[j-run+1]
+		if bonus >= v2BonusBoundary && bonus > firstBonus {
+			run = 1
+		} else {
+			bonus = max(bonus, firstBonus, v2BonusConsecutive)
+		}
+	}
+
+	if diag+bonus < gap {
+		return diag + f.bonus[j], 0
+	}
+
+	return diag + bonus, run
+}
+
+func (f *fuzzyV2) backtrack(m int, n int, j int) []int {
+	H, C := f.scores[:m*n], f.consecutive[:m*n]
+	positions := make([]int, m)
+
+	preferMatch := true
+	for i := m - 1; ; {
+		row := i * n
+		s := H[row+j]
+
+		s1, s2 := 0, 0
+		if i > 0 && j > f.first[i-1] {
+			s1 = H[row-n+j-1]
+		}
+
+		if j > f.first[i] {
+			s2 = H[row+j-1]
+		}
+
+		if C[row+j] > 0 && s > s1 && (s > s2 || s == s2 && preferMatch) {
+			positions[i] = j
+			if i == 0 {
+				break
+			}
+
+			i--
+		}
+
+		preferMatch = C[row+j] > 1 || j+1 < n && row+n+j+1 < len(C) && C[row+n+j+1] > 0
+		j--
+	}
+
+	return positions
+}
+
+// greedy aligns pattern from its earliest possible end backwards, which
+// tightens the leftmost match, and scores that alignment with the v2 bonuses.
+// It needs no matrix, so it handles candidates too long for match.
+func (f *fuzzyV2) greedy(pattern []rune, folded []rune) (int, []int, bool) {
+	m := len(pattern)
+	positions := make([]int, m)
+
+	for i, j := m-1, f.first[m-1]; i >= 0; i, j = i-1, j-1 {
+		for folded[j] != pattern[i] {
+			j--
+		}
+
+		positions[i] = j
+	}
+
+	score, run, firstBonus, inGap := 0, 0, 0, false
+
+	for i, j := 0, positions[0]; j <= positions[m-1]; j++ {
+		if j != positions[i] {
+			if inGap {
+				score += v2ScoreGapExtension
+			} else {
+				score += v2ScoreGapStart
+			}
+
+			inGap, run, firstBonus = true, 0, 0
+
+			continue
+		}
+
+		bonus := f.bonus[j]
+		if run == 0 {
+			firstBonus = bonus
+		} else {
+			if bonus >= v2BonusBoundary && bonus > firstBonus {
+				firstBonus = bonus
+			}
+
+			bonus = max(bonus, firstBonus, v2BonusConsecutive)
+		}
+
+		if i == 0 {
+			bonus *= v2BonusFirstCharFactor
+		}
+
+		score += v2ScoreMatch + bonus
+		inGap = false
+		run++
+		i++
+	}
+
+	return score, positions, true
+}
+
+// fuzzyV2Find matches pattern against every candidate with fuzzyV2, best
+// first. Ties go to the shorter candidate.
+func fuzzyV2Find(pattern string, candidates []string, sensitive bool) []Match {
+	folded := foldRunes(pattern, sensitive)
+
+	var (
+		f       fuzzyV2
+		matches []Match
+	)
+
+	for i, c := range candidates {
+		original := []rune(c)
+
+		score, positions, ok := f.match(folded, foldRunes(c, sensitive), original)
+		if !ok {
+			continue
+		}
+
+		matches = append(matches, Match{
+			Index:         i,
+			Score:         score,
+			MatchedRanges: positionRanges(positions),
+		})
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].Score != matches[b].Score {
+			return matches[a].Score > matches[b].Score
+		}
+
+		return len(candidates[matches[a].Index]) < len(candidates[matches[b].Index])
+	})
+
+	return matches
+}
+
+// positionRanges joins sorted rune positions into ranges.
+func positionRanges(positions []int) []Range {
+	var ranges []Range
+
+	for _, p := range positions {
+		if n := len(ranges); n > 0 && ranges[n-1].End == p {
+			ranges[n-1].End++
+
+			continue
+		}
+
+		ranges = append(ranges, Range{Start: p, End: p + 1})
+	}
+
+	return ranges
+}
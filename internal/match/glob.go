@@ -1,28 +1,230 @@
 package match
 
-import "github.com/bmatcuk/doublestar/v4"
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
 
-type GlobMatcher struct{}
+const globMatchScore = 100
+
+var errGlobUnclosedClass = errors.New("unclosed character class")
+
+// GlobMatcher matches whole commands against a glob pattern. "*" and "?"
+// do not cross "/", "**" does, and "[...]" and "{a,b}" work as in shells.
+// Literal text and single-character wildcards are highlighted, and matches
+// that leave less to "*" score higher.
+type GlobMatcher struct {
+	Case CaseMode
+}
+
+type globGroupKind int
+
+const (
+	globLiteral globGroupKind = iota
+	globSingle
+	globStar
+)
 
 func (m *GlobMatcher) Match(pattern string, candidates []string) []Match {
 	if pattern == "" {
 		return nil
 	}
 
+	re, kinds, err := compileGlob(pattern, m.Case.Sensitive(pattern))
+	if err != nil {
+		return nil
+	}
+
 	var matches []Match
 
 	for i, c := range candidates {
-		ok, _ := doublestar.Match(pattern, c)
-		if !ok {
+		loc := re.FindStringSubmatchIndex(c)
+		if loc == nil {
 			continue
 		}
 
+		runeStarts := buildRuneByteOffsets(c)
+
+		var (
+			ranges   []Range
+			matched  int
+			consumed int
+		)
+
+		for g, kind := range kinds {
+			start := byteOffsetToRuneIndex(runeStarts, loc[2*g+2])
+			end := byteOffsetToRuneIndex(runeStarts, loc[2*g+3])
+
+			if kind == globStar {
+				consumed += end - start
+
+				continue
+			}
+
+			matched += end - start
+
+			if end > start {
+				ranges = append(ranges, Range{Start: start, End: end})
+			}
+		}
+
+		score := 1
+		if matched > 0 {
+			score = max(globMatchScore*matched/(matched+consumed), 1)
+		}
+
 		matches = append(matches, Match{
 			Index:         i,
-			Score:         1,
-			MatchedRanges: nil,
+			Score:         score,
+			MatchedRanges: mergeRanges(ranges),
 		})
 	}
 
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].Score > matches[b].Score
+	})
+
 	return matches
 }
+
+// compileGlob translates pattern into an anchored regexp with one capture
+// group per literal run or wildcard, and returns the kind of each group.
+// Stars are lazy so the literal parts absorb as much as they can.
+func compileGlob(pattern string, sensitive bool) (*regexp.Regexp, []globGroupKind, error) {
+	var (
+		expr    strings.Builder
+		kinds   []globGroupKind
+		literal strings.Builder
+	)
+
+	group := func(kind globGroupKind, body string) {
+		expr.WriteString("(" + body + ")")
+		kinds = append(kinds, kind)
+	}
+
+	flush := func() {
+		if literal.Len() > 0 {
+			group(globLiteral, regexp.QuoteMeta(literal.String()))
+			literal.Reset()
+		}
+	}
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+
+			literal.WriteRune(runes[i])
+		case '*':
+			flush()
+
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				for i+1 < len(runes) && runes[i+1] == '*' {
+					i++
+				}
+
+				group(globStar, ".*?")
+			} else {
+				group(globStar, "[^/]*?")
+			}
+		case '?':
+			flush()
+			group(globSingle, "[^/]")
+		case '[':
+			class, next, err := globClass(runes, i)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			flush()
+			group(globSingle, class)
+
+			i = next
+		case '{':
+			alternatives, next, ok := globAlternatives(runes, i)
+			if !ok {
+				literal.WriteRune(r)
+
+				continue
+			}
+
+			flush()
+			group(globLiteral, alternatives)
+
+			i = next
+		default:
+			literal.WriteRune(r)
+		}
+	}
+
+	flush()
+
+	flags := "(?si)"
+	if sensitive {
+		flags = "(?s)"
+	}
+
+	re, err := regexp.Compile(flags + "^" + expr.String() + "$")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return re, kinds, nil
+}
+
+// globClass translates the "[...]" class starting at runes[start] and
+// returns the index of its closing bracket.
+func globClass(runes []rune, start int) (string, int, error) {
+	var class strings.Builder
+
+	class.WriteString("[")
+
+	i := start + 1
+	if i < len(runes) && (runes[i] == '!' || runes[i] == '^') {
+		class.WriteString("^")
+
+		i++
+	}
+
+	first := i
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		if r == ']' && i > first {
+			class.WriteString("]")
+
+			return class.String(), i, nil
+		}
+
+		if r == '-' {
+			class.WriteRune(r)
+		} else {
+			class.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	return "", 0, errGlobUnclosedClass
+}
+
+// globAlternatives translates the "{a,b}" group starting at runes[start]
+// into a regexp alternation of literals.
+func globAlternatives(runes []rune, start int) (string, int, bool) {
+	for end := start + 1; end < len(runes); end++ {
+		if runes[end] != '}' {
+			continue
+		}
+
+		parts := strings.Split(string(runes[start+1:end]), ",")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+
+		return strings.Join(parts, "|"), end, true
+	}
+
+	return "", 0, false
+}
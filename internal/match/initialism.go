@@ -0,0 +1,239 @@
+package match
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	initialismBaseScore   = 120
+	initialismCharScore   = 10
+	initialismSkipPenalty = 8
+	initialismTypoPenalty = 60
+	initialismMinTypoLen  = 3
+	initialismWordMarks   = "-_/.:=,@"
+	// initialismSkipWindow bounds how many words may be skipped between
+	// pieces of the term, which keeps long commands cheap to match.
+	initialismSkipWindow = 8
+)
+
+// matchInitialism matches a fuzzy term against word starts, so "gcm" finds
+// "git commit -m" and "dcu" finds "docker compose up". Each piece of the
+// term must be a prefix of a later word, at most initialismSkipWindow words
+// on; words are split on spaces, the marks in initialismWordMarks and
+// camelCase. Terms of initialismMinTypoLen or more runes may contain one
+// typo after the first rune.
+func matchInitialism(pattern string, candidates []string, sensitive bool) []Match {
+	query := foldRunes(pattern, sensitive)
+	if len(query) < 2 {
+		return nil
+	}
+
+	var (
+		solver  = initialismSolver{query: query}
+		matches []Match
+	)
+
+	for i, c := range candidates {
+		score, ranges, ok := solver.solve(foldRunes(c, sensitive), []rune(c))
+		if !ok {
+			continue
+		}
+
+		matches = append(matches, Match{
+			Index:         i,
+			Score:         max(initialismBaseScore+score, 1),
+			MatchedRanges: mergeRanges(ranges),
+		})
+	}
+
+	return matches
+}
+
+// wordStarts returns the rune index of every word start in s.
+func wordStarts(s []rune) []int {
+	var starts []int
+
+	for i, r := range s {
+		if unicode.IsSpace(r) || strings.ContainsRune(initialismWordMarks, r) {
+			continue
+		}
+
+		if i == 0 {
+			starts = append(starts, i)
+
+			continue
+		}
+
+		prev := s[i-1]
+		if unicode.IsSpace(prev) || strings.ContainsRune(initialismWordMarks, prev) ||
+			(unicode.IsLower(prev) && unicode.IsUpper(r)) {
+			starts = append(starts, i)
+		}
+	}
+
+	return starts
+}
+
+type initialismStep int
+
+const (
+	initialismChunk initialismStep = iota
+	initialismTypoWord
+	initialismTypoHere
+)
+
+// initialismState is the memoized best path from one (qi, wi, typos)
+// state: its score and the first step taken.
+type initialismState struct {
+	stamp  int
+	ok     bool
+	score  int
+	step   initialismStep
+	word   int
+	length int
+}
+
+// initialismSolver is reused across candidates; stamp invalidates the memo
+// without clearing it.
+type initialismSolver struct {
+	query    []rune
+	text     []rune
+	starts   []int
+	nextWord []int
+	memo     []initialismState
+	stamp    int
+}
+
+// solve prepares the solver for one candidate and returns its best path.
+func (s *initialismSolver) solve(text []rune, original []rune) (int, []Range, bool) {
+	s.text = text
+	s.starts = wordStarts(original)
+
+	if !s.startsWithFirstRune() {
+		return 0, nil, false
+	}
+
+	s.nextWord = s.nextWord[:0]
+	for pos, w := 0, 0; pos <= len(text); pos++ {
+		for w < len(s.starts) && s.starts[w] < pos {
+			w++
+		}
+
+		s.nextWord = append(s.nextWord, w)
+	}
+
+	size := (len(s.query) + 1) * (len(s.starts) + 1) * 2
+	if len(s.memo) < size {
+		s.memo = make([]initialismState, size)
+	}
+
+	s.stamp++
+
+	state := s.best(0, 0, 0)
+	if !state.ok {
+		return 0, nil, false
+	}
+
+	return state.score, s.ranges(), true
+}
+
+func (s *initialismSolver) startsWithFirstRune() bool {
+	for _, start := range s.starts {
+		if s.text[start] == s.query[0] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *initialismSolver) state(qi int, wi int, typos int) *initialismState {
+	return &s.memo[(qi*(len(s.starts)+1)+wi)*2+typos]
+}
+
+// best finds the highest-scoring way to match query[qi:] against the words
+// from index wi on, with typos already spent. The first piece may start at
+// any word; later ones within initialismSkipWindow words.
+func (s *initialismSolver) best(qi int, wi int, typos int) initialismState {
+	if qi == len(s.query) {
+		return initialismState{stamp: s.stamp, ok: true, score: 0, step: initialismChunk, word: 0, length: 0}
+	}
+
+	memo := s.state(qi, wi, typos)
+	if memo.stamp == s.stamp {
+		return *memo
+	}
+
+	best := initialismState{stamp: s.stamp, ok: false, score: 0, step: initialismChunk, word: 0, length: 0}
+	consider := func(score int, step initialismStep, word int, length int, rest initialismState) {
+		if !rest.ok || (best.ok && score+rest.score <= best.score) {
+			return
+		}
+
+		best = initialismState{stamp: s.stamp, ok: true, score: score + rest.score, step: step, word: word, length: length}
+	}
+
+	canTypo := typos == 0 && qi > 0 && len(s.query) >= initialismMinTypoLen
+
+	last := len(s.starts)
+	if qi > 0 {
+		last = min(last, wi+initialismSkipWindow)
+	}
+
+	for w := wi; w < last; w++ {
+		skip := (w - wi) * initialismSkipPenalty
+		pos := s.starts[w]
+
+		for n := 1; s.prefixMatches(qi, pos, n); n++ {
+			consider(n*initialismCharScore-skip, initialismChunk, w, n, s.best(qi+n, s.nextWord[pos+n], typos))
+		}
+
+		if canTypo {
+			consider(-skip-initialismTypoPenalty, initialismTypoWord, w, 0, s.best(qi+1, w+1, 1))
+		}
+	}
+
+	if canTypo {
+		consider(-initialismTypoPenalty, initialismTypoHere, wi, 0, s.best(qi+1, wi, 1))
+	}
+
+	*memo = best
+
+	return best
+}
+
+// ranges follows the memoized steps from the start state.
+func (s *initialismSolver) ranges() []Range {
+	var ranges []Range
+
+	for qi, wi, typos := 0, 0, 0; qi < len(s.query); {
+		state := s.state(qi, wi, typos)
+
+		switch state.step {
+		case initialismChunk:
+			pos := s.starts[state.word]
+			ranges = append(ranges, Range{Start: pos, End: pos + state.length})
+			qi += state.length
+			wi = s.nextWord[pos+state.length]
+		case initialismTypoWord:
+			qi, wi, typos = qi+1, state.word+1, 1
+		case initialismTypoHere:
+			qi, typos = qi+1, 1
+		}
+	}
+
+	return ranges
+}
+
+// prefixMatches reports whether query[qi:qi+n] occurs at text[pos:] without
+// crossing whitespace.
+func (s *initialismSolver) prefixMatches(qi int, pos int, n int) bool {
+	if qi+n > len(s.query) || pos+n > len(s.text) {
+		return false
+	}
+
+	r := s.text[pos+n-1]
+
+	return r == s.query[qi+n-1] && !unicode.IsSpace(r)
+}
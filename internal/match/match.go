@@ -6,6 +6,7 @@ const (
 	ModeFuzzy Mode = iota
 	ModeRegex
 	ModeGlob
+	ModeSubstring
 )
 
 func (m Mode) String() string {
@@ -16,6 +17,8 @@ func (m Mode) String() string {
 		return "regex"
 	case ModeGlob:
 		return "glob"
+	case ModeSubstring:
+		return "substring"
 	default:
 		return "unknown"
 	}
@@ -29,6 +32,8 @@ func ParseMode(s string) (Mode, bool) {
 		return ModeRegex, true
 	case "glob":
 		return ModeGlob, true
+	case "substring":
+		return ModeSubstring, true
 	default:
 		return ModeFuzzy, false
 	}
@@ -63,15 +68,17 @@ type Matcher interface {
 	Match(pattern string, candidates []string) []Match
 }
 
-func New(mode Mode) Matcher {
+func New(mode Mode, caseMode CaseMode, algorithm FuzzyAlgorithm) Matcher {
 	switch mode {
 	case ModeFuzzy:
-		return &FuzzyMatcher{}
+		return &FuzzyMatcher{Case: caseMode, Algorithm: algorithm}
 	case ModeRegex:
-		return &RegexMatcher{}
+		return &RegexMatcher{Case: caseMode}
 	case ModeGlob:
-		return &GlobMatcher{}
+		return &GlobMatcher{Case: caseMode}
+	case ModeSubstring:
+		return &SubstringMatcher{Case: caseMode}
 	default:
-		return &FuzzyMatcher{}
+		return &FuzzyMatcher{Case: caseMode, Algorithm: algorithm}
 	}
 }
@@ -1,6 +1,14 @@
 package match
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
 
 func TestFuzzyMatcher(t *testing.T) {
 	m := &FuzzyMatcher{}
@@ -66,6 +74,32 @@ func TestRegexMatcherUsesRuneRanges(t *testing.T) {
 	}
 }
 
+func TestSubstringMatcher(t *testing.T) {
+	m := &SubstringMatcher{}
+	candidates := []string{"cat a.(b)|c", "grep a.(b) log", "Make build", "make test"}
+
+	matches := m.Match("a.(b)", candidates)
+	if len(matches) != 2 {
+		t.Fatalf("substring 'a.(b)' matched %d candidates, want 2", len(matches))
+	}
+
+	if got, want := matches[0].MatchedRanges, []Range{{Start: 4, End: 9}}; !slices.Equal(got, want) {
+		t.Fatalf("matched ranges = %+v, want %+v", got, want)
+	}
+
+	if got := matchedCommands(m.Match("make b", candidates), candidates); !slices.Equal(got, []string{"Make build"}) {
+		t.Fatalf("ANDed terms matched %v, want [Make build]", got)
+	}
+
+	if got := matchedCommands(m.Match("Make", candidates), candidates); !slices.Equal(got, []string{"Make build"}) {
+		t.Fatalf("uppercase term matched %v, want only [Make build]", got)
+	}
+
+	if got := m.Match("t", []string{"test it"}); !slices.Equal(got[0].MatchedRanges, []Range{{0, 1}, {3, 4}, {6, 7}}) {
+		t.Fatalf("matched ranges = %+v, want every occurrence", got[0].MatchedRanges)
+	}
+}
+
 func TestGlobMatcher(t *testing.T) {
 	m := &GlobMatcher{}
 	candidates := []string{"git checkout", "git commit", "go build", "echo hello"}
@@ -81,6 +115,37 @@ func TestGlobMatcher(t *testing.T) {
 	}
 }
 
+func TestGlobMatcherHighlightsAndPrefersTighterMatches(t *testing.T) {
+	m := &GlobMatcher{}
+	candidates := []string{"git checkout feature/login", "git commit", "go build", "git c"}
+
+	matches := m.Match("git c*", candidates)
+	if got, want := matchedCommands(matches, candidates), []string{"git c", "git commit"}; !slices.Equal(got, want) {
+		t.Fatalf("glob 'git c*' order = %v, want %v", got, want)
+	}
+
+	if got := matchedCommands(m.Match("git c**", candidates), candidates); len(got) != 3 {
+		t.Fatalf("glob 'git c**' matched %v, want all git commands", got)
+	}
+
+	if got, want := matches[1].MatchedRanges, []Range{{Start: 0, End: 5}}; !slices.Equal(got, want) {
+		t.Fatalf("matched ranges = %+v, want %+v", got, want)
+	}
+
+	matches = m.Match("g? {build,test}", candidates)
+	if len(matches) != 1 || !slices.Equal(matches[0].MatchedRanges, []Range{{Start: 0, End: 8}}) {
+		t.Fatalf("glob 'g? {build,test}' = %+v, want go build fully highlighted", matches)
+	}
+
+	if got := matchedCommands(m.Match("git c*/[lm]ogin", candidates), candidates); !slices.Equal(got, []string{"git checkout feature/login"}) {
+		t.Fatalf("glob with class matched %v", got)
+	}
+
+	if got := m.Match("git [a", candidates); len(got) != 0 {
+		t.Fatalf("unclosed class matched %v, want none", got)
+	}
+}
+
 func TestModeNext(t *testing.T) {
 	all := []Mode{ModeFuzzy, ModeRegex, ModeGlob}
 	if ModeFuzzy.Next(all) != ModeRegex {
@@ -106,15 +171,376 @@ func TestModeNext(t *testing.T) {
 }
 
 func TestNew(t *testing.T) {
-	if _, ok := New(ModeFuzzy).(*FuzzyMatcher); !ok {
+	if _, ok := New(ModeFuzzy, CaseSmart, FuzzyV2).(*FuzzyMatcher); !ok {
 		t.Error("New(ModeFuzzy) should return *FuzzyMatcher")
 	}
 
-	if _, ok := New(ModeRegex).(*RegexMatcher); !ok {
+	if _, ok := New(ModeRegex, CaseSmart, FuzzyV2).(*RegexMatcher); !ok {
 		t.Error("New(ModeRegex) should return *RegexMatcher")
 	}
 
-	if _, ok := New(ModeGlob).(*GlobMatcher); !ok {
+	if _, ok := New(ModeGlob, CaseSmart, FuzzyV2).(*GlobMatcher); !ok {
 		t.Error("New(ModeGlob) should return *GlobMatcher")
 	}
+
+	if _, ok := New(ModeSubstring, CaseSmart, FuzzyV2).(*SubstringMatcher); !ok {
+		t.Error("New(ModeSubstring) should return *SubstringMatcher")
+	}
+}
+
+func matchedCommands(matches []Match, candidates []string) []string {
+	got := make([]string, len(matches))
+	for i, m := range matches {
+		got[i] = candidates[m.Index]
+	}
+
+	sort.Strings(got)
+
+	return got
+}
+
+func TestFuzzyMatcherExtendedSyntax(t *testing.T) {
+	m := &FuzzyMatcher{}
+	candidates := []string{
+		"sudo docker ps",
+		"docker compose up",
+		"docker build .",
+		"sudo systemctl restart docker",
+		"make test",
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"docker !compose ^sudo", []string{"sudo docker ps", "sudo systemctl restart docker"}},
+		{"'build", []string{"docker build ."}},
+		{"docker$", []string{"sudo systemctl restart docker"}},
+		{"^make | compose", []string{"docker compose up", "make test"}},
+		{"!docker", []string{"make test"}},
+		{"^make test$", []string{"make test"}},
+		{"^MAKE", nil},
+	}
+
+	for _, tc := range tests {
+		got := matchedCommands(m.Match(tc.query, candidates), candidates)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("Match(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestCaseModesApplyToEveryMode(t *testing.T) {
+	candidates := []string{"Makefile build", "makefile build"}
+
+	tests := []struct {
+		mode    Mode
+		pattern string
+	}{
+		{ModeFuzzy, "Mkf"},
+		{ModeFuzzy, "'Make"},
+		{ModeSubstring, "Make"},
+		{ModeRegex, "^Make"},
+		{ModeGlob, "Make*"},
+	}
+
+	for _, tc := range tests {
+		lower := strings.ToLower(tc.pattern)
+
+		if got := matchedCommands(New(tc.mode, CaseSmart, FuzzyV2).Match(tc.pattern, candidates), candidates); !slices.Equal(got, []string{"Makefile build"}) {
+			t.Errorf("%v smart %q = %v, want only the capitalized command", tc.mode, tc.pattern, got)
+		}
+
+		if got := New(tc.mode, CaseSmart, FuzzyV2).Match(lower, candidates); len(got) != 2 {
+			t.Errorf("%v smart %q matched %d, want 2", tc.mode, lower, len(got))
+		}
+
+		if got := New(tc.mode, CaseInsensitive, FuzzyV2).Match(tc.pattern, candidates); len(got) != 2 {
+			t.Errorf("%v insensitive %q matched %d, want 2", tc.mode, tc.pattern, len(got))
+		}
+
+		if got := matchedCommands(New(tc.mode, CaseSensitive, FuzzyV2).Match(lower, candidates), candidates); !slices.Equal(got, []string{"makefile build"}) {
+			t.Errorf("%v sensitive %q = %v, want only the lowercase command", tc.mode, lower, got)
+		}
+	}
+}
+
+func TestSmartCaseIgnoresRegexEscapes(t *testing.T) {
+	if (CaseSmart).Sensitive(`make\S+`) {
+		t.Fatal(`Sensitive("make\S+") = true, want false`)
+	}
+}
+
+func TestFuzzyMatcherPrefersInitialisms(t *testing.T) {
+	m := &FuzzyMatcher{}
+	candidates := []string{
+		"go clean -modcache",
+		"git commit -m",
+		"docker container update",
+		"docker compose up",
+		"dcu",
+	}
+
+	matches := m.Match("gcm", candidates)
+	if got := candidates[matches[0].Index]; got != "git commit -m" {
+		t.Fatalf("Match(gcm) ranked %q first, want %q", got, "git commit -m")
+	}
+
+	want := []Range{{Start: 0, End: 1}, {Start: 4, End: 5}, {Start: 12, End: 13}}
+	if !slices.Equal(matches[0].MatchedRanges, want) {
+		t.Fatalf("MatchedRanges = %+v, want %+v", matches[0].MatchedRanges, want)
+	}
+
+	if got := candidates[m.Match("dcomup", candidates)[0].Index]; got != "docker compose up" {
+		t.Fatalf("Match(dcomup) ranked %q first, want docker compose up", got)
+	}
+}
+
+func TestInitialismToleratesOneTypo(t *testing.T) {
+	candidates := []string{"docker compose up", "kubectl get pods"}
+
+	if got := matchedCommands(matchInitialism("dxu", candidates, false), candidates); !slices.Equal(got, []string{"docker compose up"}) {
+		t.Fatalf("matchInitialism(dxu) = %v, want [docker compose up]", got)
+	}
+
+	if got := matchInitialism("dxxu", candidates, false); len(got) != 0 {
+		t.Fatalf("matchInitialism(dxxu) = %v, want no matches with two typos", got)
+	}
+
+	if got := matchInitialism("xcu", candidates, false); len(got) != 0 {
+		t.Fatalf("matchInitialism(xcu) = %v, want no typo in the first rune", got)
+	}
+}
+
+func TestWordStarts(t *testing.T) {
+	got := wordStarts([]rune("git commit --amend ./src/mainFile.go"))
+	want := []int{0, 4, 13, 21, 25, 29, 34}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("wordStarts() = %v, want %v", got, want)
+	}
+}
+
+func TestFuzzyMatcherMergesTermRanges(t *testing.T) {
+	m := &FuzzyMatcher{}
+	candidates := []string{"git commit -m"}
+
+	matches := m.Match("'git 'it !push", candidates)
+	if len(matches) != 1 {
+		t.Fatalf("Match() returned %d matches, want 1", len(matches))
+	}
+
+	want := []Range{{Start: 0, End: 3}}
+	if !slices.Equal(matches[0].MatchedRanges, want) {
+		t.Fatalf("MatchedRanges = %+v, want %+v", matches[0].MatchedRanges, want)
+	}
+}
+
+func TestFuzzyMatcherUsesRuneRanges(t *testing.T) {
+	m := &FuzzyMatcher{}
+	candidates := []string{"écho x"}
+
+	matches := m.Match("x", candidates)
+	if len(matches) != 1 {
+		t.Fatalf("Match() returned %d matches, want 1", len(matches))
+	}
+
+	want := []Range{{Start: 5, End: 6}}
+	if !slices.Equal(matches[0].MatchedRanges, want) {
+		t.Fatalf("MatchedRanges = %+v, want %+v", matches[0].MatchedRanges, want)
+	}
+}
+
+func TestFuzzyV2RewardsBoundariesAndSubstrings(t *testing.T) {
+	m := &FuzzyMatcher{Algorithm: FuzzyV2}
+	candidates := []string{
+		"echo ccooommmiiittt",
+		"git commit -m fix",
+		"cat src/commands/init.go",
+	}
+
+	matches := m.Match("commit", candidates)
+	if got := candidates[matches[0].Index]; got != "git commit -m fix" {
+		t.Fatalf("Match(commit) ranked %q first, want the exact substring", got)
+	}
+
+	if want := []Range{{Start: 4, End: 10}}; !slices.Equal(matches[0].MatchedRanges, want) {
+		t.Fatalf("MatchedRanges = %+v, want %+v", matches[0].MatchedRanges, want)
+	}
+
+	matches = m.Match("cfg", []string{"vim cfg.toml", "cd ~/.config/zgod"})
+	if want := []Range{{Start: 4, End: 7}}; matches[0].Index != 0 || !slices.Equal(matches[0].MatchedRanges, want) {
+		t.Fatalf("Match(cfg) = %+v, want the contiguous word first", matches)
+	}
+}
+
+func TestFuzzyV2PrefersSeparatorBoundaries(t *testing.T) {
+	var f fuzzyV2
+
+	text := []rune("xbar foo_bar")
+
+	_, positions, ok := f.match([]rune("bar"), text, text)
+	if !ok || !slices.Equal(positions, []int{9, 10, 11}) {
+		t.Fatalf("positions = %v, want the match after _", positions)
+	}
+
+	if _, _, ok := f.match([]rune("rab"), text, text); ok {
+		t.Fatal("match(rab) should fail: not a subsequence")
+	}
+}
+
+func TestFuzzyV2IgnoresConsecutiveRunsOutsideTheMatrix(t *testing.T) {
+	text := []rune("  -bbb-b")
+
+	// Scratch space left over from a longer candidate must not leak into
+	// the alignment of a shorter one.
+	reused := fuzzyV2{scores: make([]int, 64), consecutive: slices.Repeat([]int{1}, 64)}
+
+	_, positions, ok := reused.match([]rune("b-"), text, text)
+	if !ok || !slices.Equal(positions, []int{3, 6}) {
+		t.Fatalf("positions = %v, want [3 6]", positions)
+	}
+}
+
+func TestFuzzyV2FallsBackToGreedyForLongCandidates(t *testing.T) {
+	var f fuzzyV2
+
+	text := []rune(strings.Repeat("x", v2MaxMatrix) + " git status")
+
+	score, positions, ok := f.match([]rune("gst"), text, text)
+	if !ok || score <= 0 {
+		t.Fatalf("match() = %d, %v, want a positive score", score, ok)
+	}
+
+	if want := []int{v2MaxMatrix + 1, v2MaxMatrix + 5, v2MaxMatrix + 6}; !slices.Equal(positions, want) {
+		t.Fatalf("positions = %v, want %v", positions, want)
+	}
+
+	if len(f.scores) > 0 {
+		t.Fatalf("allocated a %d cell score matrix, want none", len(f.scores))
+	}
+}
+
+func TestFuzzyAlgorithmsAgreeOnMembership(t *testing.T) {
+	candidates := []string{"git status", "go test ./...", "Makefile build", "kubectl get pods"}
+
+	for _, pattern := range []string{"gst", "test", "MAKE", "kgp", "zz"} {
+		v2 := matchedCommands(New(ModeFuzzy, CaseSmart, FuzzyV2).Match(pattern, candidates), candidates)
+		classic := matchedCommands(New(ModeFuzzy, CaseSmart, FuzzyClassic).Match(pattern, candidates), candidates)
+
+		if !slices.Equal(v2, classic) {
+			t.Errorf("Match(%q): v2 = %v, classic = %v", pattern, v2, classic)
+		}
+	}
+}
+
+func TestParseFuzzyAlgorithm(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want FuzzyAlgorithm
+		ok   bool
+	}{
+		{"", FuzzyClassic, true},
+		{"classic", FuzzyClassic, true},
+		{"v2", FuzzyV2, true},
+		{"v3", FuzzyClassic, false},
+	} {
+		got, ok := ParseFuzzyAlgorithm(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("ParseFuzzyAlgorithm(%q) = %v, %v, want %v, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestParallelMatchesAcrossChunks(t *testing.T) {
+	candidates := make([]string, 3*parallelChunkSize+7)
+	for i := range candidates {
+		candidates[i] = fmt.Sprintf("echo %d", i)
+	}
+
+	candidates[5] = "git status"
+	candidates[len(candidates)-1] = "git stash"
+
+	matches, err := Parallel(context.Background(), New(ModeSubstring, CaseSmart, FuzzyV2), "git", candidates)
+	if err != nil {
+		t.Fatalf("Parallel() error: %v", err)
+	}
+
+	if got := matchedCommands(matches, candidates); !slices.Equal(got, []string{"git stash", "git status"}) {
+		t.Fatalf("Parallel() = %v, want both git commands", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Parallel(ctx, New(ModeSubstring, CaseSmart, FuzzyV2), "git", candidates); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Parallel(canceled) error = %v, want context.Canceled", err)
+	}
+}
+
+func TestModeNarrows(t *testing.T) {
+	for _, tc := range []struct {
+		mode       Mode
+		prev, next string
+		want       bool
+	}{
+		{ModeFuzzy, "git", "gitc", true},
+		{ModeFuzzy, "git", "git c", true},
+		{ModeFuzzy, "gi", "git", false},
+		{ModeFuzzy, "'gi", "'git", true},
+		{ModeFuzzy, "!git", "!gits", false},
+		{ModeFuzzy, "git", "git | go", false},
+		{ModeFuzzy, "git |", "git | go", false},
+		{ModeFuzzy, "'", "'git", false},
+		{ModeFuzzy, "git", "got", false},
+		{ModeSubstring, "g", "gi", true},
+		{ModeRegex, "git", "git|go", false},
+		{ModeGlob, "git", "git*", false},
+	} {
+		if got := tc.mode.Narrows(tc.prev, tc.next); got != tc.want {
+			t.Errorf("%v.Narrows(%q, %q) = %v, want %v", tc.mode, tc.prev, tc.next, got, tc.want)
+		}
+	}
+}
+
+// longHistory mixes short commands with a few multi-kilobyte scripts.
+func longHistory() []string {
+	words := []string{"echo", "docker", "compose", "up", "--build", "cat", "/etc/hosts", "| grep", "ab", "&&", "cd", "build"}
+
+	candidates := make([]string, 0, 10050)
+	for i := range 10000 {
+		candidates = append(candidates, fmt.Sprintf("%s %s %d", words[i%len(words)], words[(i*7)%len(words)], i))
+	}
+
+	for i := range 50 {
+		var b strings.Builder
+		for b.Len() < 5000 {
+			b.WriteString(words[(b.Len()+i)%len(words)])
+			b.WriteString(" ")
+
+			if b.Len()%7 == 0 {
+				b.WriteString("\n")
+			}
+		}
+
+		candidates = append(candidates, b.String())
+	}
+
+	return candidates
+}
+
+func BenchmarkFuzzyLongCommands(b *testing.B) {
+	candidates := longHistory()
+
+	for _, algorithm := range []FuzzyAlgorithm{FuzzyClassic, FuzzyV2} {
+		for _, pattern := range []string{"dcub", "ecab"} {
+			b.Run(algorithm.String()+"/"+pattern, func(b *testing.B) {
+				m := New(ModeFuzzy, CaseSmart, algorithm)
+
+				for b.Loop() {
+					m.Match(pattern, candidates)
+				}
+			})
+		}
+	}
 }
@@ -0,0 +1,117 @@
+package match
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+const parallelChunkSize = 1024
+
+// Parallel runs matcher over candidates in chunks spread across every core.
+// Workers stop picking up chunks once ctx is canceled, in which case the
+// context's error is returned.
+func Parallel(ctx context.Context, matcher Matcher, pattern string, candidates []string) ([]Match, error) {
+	chunks := (len(candidates) + parallelChunkSize - 1) / parallelChunkSize
+	results := make([][]Match, chunks)
+
+	var (
+		next atomic.Int64
+		wg   sync.WaitGroup
+	)
+
+	for range min(runtime.GOMAXPROCS(0), chunks) {
+		wg.Go(func() {
+			for {
+				chunk := int(next.Add(1) - 1)
+				if chunk >= chunks || ctx.Err() != nil {
+					return
+				}
+
+				start := chunk * parallelChunkSize
+				end := min(start+parallelChunkSize, len(candidates))
+
+				matches := matcher.Match(pattern, candidates[start:end])
+				for i := range matches {
+					matches[i].Index += start
+				}
+
+				results[chunk] = matches
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	for _, r := range results {
+		matches = append(matches, r...)
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].Score > matches[b].Score
+	})
+
+	return matches, nil
+}
+
+// Narrows reports whether every candidate matching next in mode also matches
+// prev, so next only needs to be tried against prev's results. Regex and
+// glob patterns can widen as they grow, so they never narrow.
+func (m Mode) Narrows(prev string, next string) bool {
+	if strings.TrimSpace(prev) == "" || !strings.HasPrefix(next, prev) {
+		return false
+	}
+
+	switch m {
+	case ModeSubstring:
+		return true
+	case ModeFuzzy:
+		return fuzzyNarrows(prev, next[len(prev):])
+	case ModeRegex, ModeGlob:
+	}
+
+	return false
+}
+
+// fuzzyNarrows rejects edits that add or extend an operator term, and short
+// fuzzy terms, which initialism typos let match more as they grow.
+func fuzzyNarrows(prev string, appended string) bool {
+	const operators = "|!$"
+
+	if strings.ContainsAny(appended, operators) || len(parseQuery(prev, false)) == 0 {
+		return false
+	}
+
+	fields := strings.Fields(prev)
+	last := fields[len(fields)-1]
+
+	// A finished last term stays as it is and new terms are ANDed, unless
+	// it is an OR that the new term would join.
+	if strings.TrimRightFunc(prev, unicode.IsSpace) != prev || strings.TrimLeftFunc(appended, unicode.IsSpace) != appended {
+		return last != "|"
+	}
+
+	if strings.ContainsAny(last, operators) {
+		return false
+	}
+
+	if rest, ok := strings.CutPrefix(last, "'"); ok {
+		return rest != ""
+	}
+
+	if rest, ok := strings.CutPrefix(last, "^"); ok {
+		return rest != ""
+	}
+
+	return utf8.RuneCountInString(last) >= initialismMinTypoLen
+}
@@ -5,7 +5,9 @@ import (
 	"sort"
 )
 
-type RegexMatcher struct{}
+type RegexMatcher struct {
+	Case CaseMode
+}
 
 const regexMatchScore = 100
 
@@ -14,7 +16,12 @@ func (m *RegexMatcher) Match(pattern string, candidates []string) []Match {
 		return nil
 	}
 
-	re, err := regexp.Compile("(?i)" + pattern)
+	flags := "(?i)"
+	if m.Case.Sensitive(pattern) {
+		flags = ""
+	}
+
+	re, err := regexp.Compile(flags + pattern)
 	if err != nil {
 		return nil
 	}
@@ -0,0 +1,92 @@
+package match
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	substringTermScore     = 100
+	substringBoundaryBonus = 20
+)
+
+// SubstringMatcher matches space-separated literal substrings that must all
+// occur.
+type SubstringMatcher struct {
+	Case CaseMode
+}
+
+func (m *SubstringMatcher) Match(pattern string, candidates []string) []Match {
+	terms := strings.Fields(pattern)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	sensitive := m.Case.Sensitive(pattern)
+
+	needles := make([][]rune, len(terms))
+	for i, t := range terms {
+		needles[i] = foldRunes(t, sensitive)
+	}
+
+	var matches []Match
+
+	for i, c := range candidates {
+		original := []rune(c)
+		haystack := foldRunes(c, sensitive)
+		total := Match{Index: i, Score: 0, MatchedRanges: nil}
+		ok := true
+
+		for _, needle := range needles {
+			ranges := findAll(haystack, needle)
+			if len(ranges) == 0 {
+				ok = false
+
+				break
+			}
+
+			total.Score += substringTermScore
+			if isWordStart(original, ranges[0].Start) {
+				total.Score += substringBoundaryBonus
+			}
+
+			total.MatchedRanges = append(total.MatchedRanges, ranges...)
+		}
+
+		if !ok {
+			continue
+		}
+
+		total.MatchedRanges = mergeRanges(total.MatchedRanges)
+		matches = append(matches, total)
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].Score > matches[b].Score
+	})
+
+	return matches
+}
+
+// findAll returns the non-overlapping occurrences of needle in haystack.
+func findAll(haystack []rune, needle []rune) []Range {
+	var ranges []Range
+
+	for start := 0; start+len(needle) <= len(haystack); {
+		if !hasRunePrefix(haystack[start:], needle) {
+			start++
+
+			continue
+		}
+
+		ranges = append(ranges, Range{Start: start, End: start + len(needle)})
+		start += len(needle)
+	}
+
+	return ranges
+}
+
+func isWordStart(s []rune, i int) bool {
+	return i == 0 || !unicode.IsLetter(s[i-1]) && !unicode.IsDigit(s[i-1])
+}
@@ -178,6 +178,7 @@ func TestInitScriptContainsRuntimeCommandGuards(t *testing.T) {
 				"if ! __zgod_has_command; then",
 				"zgod record",
 				"selected=$(zgod search",
+				`--session "$__zgod_session_id"`,
 			},
 		},
 		{
@@ -188,6 +189,7 @@ func TestInitScriptContainsRuntimeCommandGuards(t *testing.T) {
 				"if ! __zgod_has_command; then",
 				"zgod record",
 				"selected=$(zgod search",
+				`--session "$__zgod_session_id"`,
 			},
 		},
 		{
@@ -198,6 +200,7 @@ func TestInitScriptContainsRuntimeCommandGuards(t *testing.T) {
 				"if not __zgod_has_command",
 				"zgod record",
 				"set -l selected (zgod search",
+				`--session "$__zgod_session_id"`,
 			},
 		},
 		{
@@ -208,6 +211,7 @@ func TestInitScriptContainsRuntimeCommandGuards(t *testing.T) {
 				"if (-not (__zgod_has_command)) {",
 				"$psi.FileName = \"zgod\"",
 				"$selected = zgod search",
+				`"--session=$script:__zgod_session_id"`,
 			},
 		},
 	}
@@ -0,0 +1,177 @@
+package tui
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/zigai/zgod/internal/db"
+	"github.com/zigai/zgod/internal/history"
+	"github.com/zigai/zgod/internal/match"
+)
+
+// matchesMsg carries the ranked results of match request seq.
+type matchesMsg struct {
+	seq        int
+	key        matchSignature
+	query      string
+	matches    []match.Match
+	entries    []history.ScoredEntry
+	selections map[string]int
+}
+
+// matchSignature identifies what a set of matches was computed against, so later
+// queries can only narrow results from the same candidates and settings.
+type matchSignature struct {
+	generation int
+	mode       match.Mode
+	caseMode   match.CaseMode
+	algorithm  match.FuzzyAlgorithm
+}
+
+// matchCache holds the last completed match for incremental search.
+type matchCache struct {
+	key     matchSignature
+	query   string
+	matches []match.Match
+	valid   bool
+}
+
+func (m *Model) currentMatchSignature() matchSignature {
+	return matchSignature{
+		generation: m.generation,
+		mode:       m.mode,
+		caseMode:   m.caseMode,
+		algorithm:  m.fuzzyAlgorithm,
+	}
+}
+
+// startMatch cancels any running match and queues one for query. When query
+// extends the last completed one, only that query's results are searched.
+// Selection counts not cached yet are fetched by the worker.
+func (m *Model) startMatch(query string, opts history.ScoringOpts) {
+	m.cancelMatch()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.matchCancel = cancel
+	m.matchSeq++
+
+	key := m.currentMatchSignature()
+	candidates, indexes := m.candidates, []int(nil)
+
+	if m.lastMatch.valid && m.lastMatch.key == key && m.mode.Narrows(m.lastMatch.query, query) {
+		candidates = make([]string, len(m.lastMatch.matches))
+		indexes = make([]int, len(m.lastMatch.matches))
+
+		for i, prev := range m.lastMatch.matches {
+			candidates[i] = m.candidates[prev.Index]
+			indexes[i] = prev.Index
+		}
+	}
+
+	seq, entries, repo := m.matchSeq, m.allEntries, m.repo
+	matcher := match.New(m.mode, m.caseMode, m.fuzzyAlgorithm)
+	counts, cached := m.cachedSelections(query)
+
+	m.pendingMatch = func() tea.Msg {
+		defer cancel()
+
+		if !cached {
+			counts = fetchSelectionCounts(repo, query)
+		}
+
+		opts.Selections = counts
+
+		return runMatch(ctx, seq, key, matcher, query, candidates, indexes, entries, opts)
+	}
+}
+
+// runMatch matches and ranks off the UI goroutine. A canceled match yields
+// no message.
+func runMatch(
+	ctx context.Context,
+	seq int,
+	key matchSignature,
+	matcher match.Matcher,
+	query string,
+	candidates []string,
+	indexes []int,
+	entries []db.HistoryEntry,
+	opts history.ScoringOpts,
+) tea.Msg {
+	matches, err := match.Parallel(ctx, matcher, query, candidates)
+	if err != nil {
+		return nil
+	}
+
+	if indexes != nil {
+		for i := range matches {
+			matches[i].Index = indexes[matches[i].Index]
+		}
+	}
+
+	scored := history.ScoreAndSort(entries, matches, opts)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return matchesMsg{seq: seq, key: key, query: query, matches: matches, entries: scored, selections: opts.Selections}
+}
+
+// applyMatches shows msg unless a newer match has started since.
+func (m *Model) applyMatches(msg matchesMsg) {
+	if msg.seq != m.matchSeq {
+		return
+	}
+
+	m.matchCancel = nil
+	m.matching = false
+	m.lastMatch = matchCache{
+		key:     msg.key,
+		query:   msg.query,
+		matches: msg.matches,
+		valid:   true,
+	}
+	m.rememberSelections(msg.query, msg.selections)
+	m.displayEntries = msg.entries
+	m.cursor = 0
+}
+
+// settleMatch brings the results up to date with the query on the calling
+// goroutine when a match is still queued or running, so accepting never
+// picks from the results of an older query.
+func (m *Model) settleMatch() {
+	if m.pendingMatch == nil && !m.matching {
+		return
+	}
+
+	m.updateMatches()
+
+	if cmd := m.pendingMatch; cmd != nil {
+		m.pendingMatch = nil
+		if msg, ok := cmd().(matchesMsg); ok {
+			m.applyMatches(msg)
+		}
+	}
+}
+
+func (m *Model) cancelMatch() {
+	if m.matchCancel != nil {
+		m.matchCancel()
+		m.matchCancel = nil
+	}
+
+	m.pendingMatch = nil
+	m.matching = false
+}
+
+// takeMatchCmd hands the queued match to the runtime.
+func (m *Model) takeMatchCmd() tea.Cmd {
+	cmd := m.pendingMatch
+	if cmd != nil {
+		m.pendingMatch = nil
+		m.matching = true
+	}
+
+	return cmd
+}
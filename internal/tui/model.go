@@ -1,9 +1,11 @@
 package tui
 
 import (
+	"context"
 	"slices"
-	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -16,9 +18,12 @@ import (
 
 type Model struct {
 	input          textinput.Model
+	query          history.Query
 	cfg            config.Config
 	styles         Styles
 	allEntries     []db.HistoryEntry
+	stats          map[string]db.CommandStats
+	transitions    map[string]int
 	candidates     []string
 	displayEntries []history.ScoredEntry
 	cursor         int
@@ -27,12 +32,19 @@ type Model struct {
 	maxHeight      int
 	selected       string
 	mode           match.Mode
+	caseMode       match.CaseMode
+	fuzzyAlgorithm match.FuzzyAlgorithm
 	enabledModes   []match.Mode
-	cwdMode        bool
+	scope          history.SearchScope
+	multiHost      bool
+	hostWidth      int
 	dedupe         bool
 	failFilter     db.FailFilterMode
 	cwd            string
+	gitRoot        string
 	homeDir        string
+	sessionID      string
+	hostname       string
 	quitting       bool
 	canceled       bool
 	showHelp       bool
@@ -40,9 +52,16 @@ type Model struct {
 	previewCommand string
 	repo           *db.HistoryRepo
 	dbError        error
+	generation     int
+	matchSeq       int
+	matchCancel    context.CancelFunc
+	pendingMatch   tea.Cmd
+	matching       bool
+	lastMatch      matchCache
+	selections     map[string]map[string]int
 }
 
-func NewModel(cfg config.Config, repo *db.HistoryRepo, cwd string, homeDir string, height int, cwdMode bool, initialQuery string) *Model {
+func NewModel(cfg config.Config, repo *db.HistoryRepo, cwd string, homeDir string, height int, scope history.SearchScope, initialQuery string) *Model {
 	width := 80
 
 	if height < 1 {
@@ -61,6 +80,10 @@ func NewModel(cfg config.Config, repo *db.HistoryRepo, cwd string, homeDir strin
 		enabledModes = append(enabledModes, match.ModeFuzzy)
 	}
 
+	if cfg.Display.EnableSubstring {
+		enabledModes = append(enabledModes, match.ModeSubstring)
+	}
+
 	if cfg.Display.EnableGlob {
 		enabledModes = append(enabledModes, match.ModeGlob)
 	}
@@ -76,28 +99,31 @@ func NewModel(cfg config.Config, repo *db.HistoryRepo, cwd string, homeDir strin
 		}
 	}
 
-	if cfg.Display.DefaultScope == "cwd" {
-		cwdMode = true
-	}
-
 	failFilter, _ := db.ParseFailFilterMode(cfg.Display.DefaultFailFilter)
+	caseMode, _ := match.ParseCaseMode(cfg.Display.Case)
+	fuzzyAlgorithm, _ := match.ParseFuzzyAlgorithm(cfg.Display.FuzzyAlgorithm)
 
 	m := Model{
-		input:        ti,
-		cfg:          cfg,
-		styles:       NewStyles(cfg.Theme),
-		width:        width,
-		height:       height,
-		maxHeight:    height,
-		mode:         initialMode,
-		enabledModes: enabledModes,
-		cwdMode:      cwdMode,
-		dedupe:       true,
-		failFilter:   failFilter,
-		cwd:          cwd,
-		homeDir:      homeDir,
-		repo:         repo,
+		input:          ti,
+		cfg:            cfg,
+		styles:         NewStyles(cfg.Theme),
+		width:          width,
+		height:         height,
+		maxHeight:      height,
+		mode:           initialMode,
+		caseMode:       caseMode,
+		fuzzyAlgorithm: fuzzyAlgorithm,
+		enabledModes:   enabledModes,
+		scope:          scope,
+		dedupe:         true,
+		failFilter:     failFilter,
+		cwd:            cwd,
+		gitRoot:        history.FindGitRoot(cwd),
+		homeDir:        homeDir,
+		repo:           repo,
 	}
+	m.scope.Directory = scope.Directory.Resolve(cwd, m.gitRoot)
+	m.query = m.parseQuery()
 	m.loadEntries()
 
 	return &m
@@ -112,11 +138,22 @@ func (m *Model) Canceled() bool {
 }
 
 func (m *Model) Init() tea.Cmd {
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, m.takeMatchCmd())
 }
 
+// Update handles msg and starts any match it queued.
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.update(msg)
+
+	return model, tea.Batch(cmd, m.takeMatchCmd())
+}
+
+func (m *Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case matchesMsg:
+		m.applyMatches(msg)
+
+		return m, nil
 	case tea.KeyMsg:
 		return m.handleKey(msg)
 	case tea.WindowSizeMsg:
@@ -146,12 +183,24 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m *Model) loadEntries() {
+	m.cancelMatch()
+	m.generation++
+
+	filter := m.query.Filter
+	filter.FailFilter = m.failFilter
+	filter.Scope = m.scope.Filter(m.cwd, m.gitRoot, m.sessionID, m.hostname)
+
 	entries, err := history.FetchCandidates(m.repo, history.CandidateOpts{
-		Limit:      10000,
-		Dedupe:     m.dedupe,
-		FailFilter: m.failFilter,
+		Limit:  10000,
+		Dedupe: m.dedupe,
+		Filter: filter,
 	})
 
+	m.stats = nil
+	if ranking, _ := history.ParseRanking(m.cfg.Display.Ranking); err == nil && ranking == history.RankingFrecency {
+		m.stats, err = history.FetchCommandStats(m.repo, filter)
+	}
+
 	m.dbError = err
 	if err != nil {
 		m.allEntries = nil
@@ -161,17 +210,6 @@ func (m *Model) loadEntries() {
 		return
 	}
 
-	if m.cwdMode && m.cwd != "" {
-		filtered := entries[:0:0]
-		for _, e := range entries {
-			if e.Directory == m.cwd {
-				filtered = append(filtered, e)
-			}
-		}
-
-		entries = filtered
-	}
-
 	if m.cfg.Display.HideMultiline {
 		filtered := entries[:0:0]
 		for _, e := range entries {
@@ -184,6 +222,7 @@ func (m *Model) loadEntries() {
 	}
 
 	m.allEntries = entries
+	m.multiHost, m.hostWidth = hostColumn(entries)
 
 	m.candidates = make([]string, len(entries))
 	for i, e := range entries {
@@ -193,51 +232,155 @@ func (m *Model) loadEntries() {
 	m.updateMatches()
 }
 
+func (m *Model) parseQuery() history.Query {
+	return history.ParseQuery(m.input.Value(), time.Now(), m.cwd, m.homeDir)
+}
+
+// refreshQuery reparses the input after an edit. Candidates are refetched
+// only when the qualifiers changed; otherwise the text is rematched.
+func (m *Model) refreshQuery() {
+	query := m.parseQuery()
+	reload := query.Key() != m.query.Key()
+
+	m.query = query
+	if reload {
+		m.loadEntries()
+		return
+	}
+
+	m.updateMatches()
+}
+
+// updateMatches ranks the candidates for the current query. Non-empty
+// queries are matched in the background; see startMatch.
 func (m *Model) updateMatches() {
-	query := m.input.Value()
+	m.cancelMatch()
 
-	cwdBonus := m.cfg.Display.CWDBoost
-	if m.cwdMode {
-		cwdBonus = 0
+	if m.query.Err != nil {
+		m.displayEntries = nil
+		m.cursor = 0
+
+		return
 	}
 
-	if query == "" {
-		opts := history.DefaultScoringOpts(m.cwd)
-		opts.CWDBonus = cwdBonus
-
-		scored := make([]history.ScoredEntry, len(m.allEntries))
-		for i, e := range m.allEntries {
-			score := 0
-			if opts.CWD != "" && e.Directory == opts.CWD {
-				score += opts.CWDBonus
-			}
+	query := m.query.Text
+	opts := m.scoringOpts()
 
-			recency := max(opts.RecencyBase-(i/100), 0)
-			score += recency
-			scored[i] = history.ScoredEntry{
-				Entry:      e,
-				MatchInfo:  match.Match{Index: i},
-				FinalScore: score,
-			}
+	if query == "" {
+		counts, ok := m.cachedSelections("")
+		if !ok {
+			counts = fetchSelectionCounts(m.repo, "")
+			m.rememberSelections("", counts)
 		}
 
-		sort.SliceStable(scored, func(a, b int) bool {
-			return scored[a].FinalScore > scored[b].FinalScore
-		})
-		m.displayEntries = scored
+		opts.Selections = counts
+		m.displayEntries = history.ScoreAll(m.allEntries, opts)
 		m.cursor = 0
 
 		return
 	}
 
-	matcher := match.New(m.mode)
-	matches := matcher.Match(query, m.candidates)
+	m.startMatch(query, opts)
+}
 
-	opts := history.DefaultScoringOpts(m.cwd)
-	opts.CWDBonus = cwdBonus
+func (m *Model) scoringOpts() history.ScoringOpts {
+	opts := history.NewScoringOpts(m.cwd, m.cfg.Ranking)
+	opts.GitRoot = m.gitRoot
+	opts.SessionID = m.sessionID
+	opts.Hostname = m.hostname
 
-	m.displayEntries = history.ScoreAndSort(m.allEntries, matches, opts)
-	m.cursor = 0
+	opts.CWDBonus = config.IntDefault(m.cfg.Ranking.CWDBonus, m.cfg.Display.CWDBoost)
+	if m.scope.Directory == history.ScopeExact {
+		opts.CWDBonus = 0
+	}
+
+	opts.Ranking, _ = history.ParseRanking(m.cfg.Display.Ranking)
+	opts.Stats = m.stats
+	opts.Transitions = m.transitions
+
+	return opts
+}
+
+// cachedSelections returns the selection counts already fetched for query.
+func (m *Model) cachedSelections(query string) (map[string]int, bool) {
+	counts, ok := m.selections[history.SelectionQuery(query)]
+
+	return counts, ok
+}
+
+func (m *Model) rememberSelections(query string, counts map[string]int) {
+	if m.selections == nil {
+		m.selections = make(map[string]map[string]int)
+	}
+
+	m.selections[history.SelectionQuery(query)] = counts
+}
+
+// fetchSelectionCounts looks up earlier choices for query. They only refine
+// the ranking, so lookup errors leave it unchanged.
+func fetchSelectionCounts(repo *db.HistoryRepo, query string) map[string]int {
+	if repo == nil {
+		return nil
+	}
+
+	counts, err := history.FetchSelectionCounts(repo, query)
+	if err != nil {
+		return nil
+	}
+
+	return counts
+}
+
+// SetSearchContext tells the model which shell is searching. Entries from
+// the same session or host rank higher, and commands that historically
+// followed previousCommand are boosted so an empty query suggests the
+// likely next step.
+func (m *Model) SetSearchContext(sessionID string, hostname string, previousCommand string) {
+	m.sessionID = sessionID
+	m.hostname = hostname
+	m.transitions = nil
+
+	if previousCommand != "" && m.repo != nil {
+		transitions, err := history.FetchTransitions(m.repo, previousCommand)
+		if err != nil {
+			m.dbError = err
+			return
+		}
+
+		m.transitions = transitions
+	}
+
+	if m.scope.Session || m.scope.Host {
+		m.loadEntries()
+		return
+	}
+
+	m.updateMatches()
+}
+
+// hostColumn reports whether entries were recorded on more than one host and
+// how wide their hostnames are, up to hostColumnMaxWidth.
+func hostColumn(entries []db.HistoryEntry) (bool, int) {
+	var first string
+
+	multiple := false
+	width := 0
+
+	for _, e := range entries {
+		if e.Hostname == "" {
+			continue
+		}
+
+		if first == "" {
+			first = e.Hostname
+		} else if e.Hostname != first {
+			multiple = true
+		}
+
+		width = max(width, utf8.RuneCountInString(e.Hostname))
+	}
+
+	return multiple, min(width, hostColumnMaxWidth)
 }
 
 func (m *Model) handleNavigation(msg tea.KeyMsg) bool {
@@ -295,6 +438,10 @@ func (m *Model) handleModeSwitch(msg tea.KeyMsg) bool {
 		m.mode = match.ModeRegex
 	case matchKey(msg, m.cfg.Keys.ModeGlob) && m.cfg.Display.EnableGlob:
 		m.mode = match.ModeGlob
+	case matchKey(msg, m.cfg.Keys.ModeSubstring) && m.cfg.Display.EnableSubstring:
+		m.mode = match.ModeSubstring
+	case matchKey(msg, m.cfg.Keys.ToggleCase):
+		m.caseMode = m.caseMode.Next()
 	default:
 		return false
 	}
@@ -307,7 +454,11 @@ func (m *Model) handleModeSwitch(msg tea.KeyMsg) bool {
 func (m *Model) handleToggle(msg tea.KeyMsg) bool {
 	switch {
 	case matchKey(msg, m.cfg.Keys.ToggleCWD):
-		m.cwdMode = !m.cwdMode
+		m.scope.Directory = m.scope.Directory.Next(m.cwd, m.gitRoot)
+	case matchKey(msg, m.cfg.Keys.ToggleSession):
+		m.scope.Session = !m.scope.Session
+	case matchKey(msg, m.cfg.Keys.ToggleHost):
+		m.scope.Host = !m.scope.Host
 	case matchKey(msg, m.cfg.Keys.ToggleDedupe):
 		m.dedupe = !m.dedupe
 	case matchKey(msg, m.cfg.Keys.ToggleFails):
@@ -336,7 +487,7 @@ func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	m.input, cmd = m.input.Update(msg)
 	if m.input.Value() != prevValue {
-		m.updateMatches()
+		m.refreshQuery()
 	}
 
 	return m, cmd
@@ -387,8 +538,16 @@ func (m *Model) handleControlKeys(msg tea.KeyMsg) (tea.Cmd, bool) {
 }
 
 func (m *Model) acceptCurrentSelection() {
+	m.settleMatch()
+
 	if cmd, ok := m.currentResultCommand(); ok {
 		m.selected = cmd
+
+		if m.repo != nil {
+			// Learning from the choice must not block accepting it.
+			_ = history.RecordSelection(m.repo, m.query.Text, m.mode.String(), m.displayEntries[m.cursor].Entry, m.cursor)
+		}
+
 		return
 	}
 
@@ -1,7 +1,9 @@
 package tui
 
 import (
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/charmbracelet/bubbles/textinput"
@@ -181,7 +183,7 @@ func TestHandleToggleFailsCyclesFailFilterModesAndReloadsEntries(t *testing.T) {
 	}
 
 	cfg := config.Default()
-	m := NewModel(cfg, repo, "", "", 10, false, "")
+	m := NewModel(cfg, repo, "", "", 10, history.SearchScope{}, "")
 
 	if got, want := m.failFilter, db.FailFilterInclude; got != want {
 		t.Fatalf("initial failFilter = %v, want %v", got, want)
@@ -244,7 +246,7 @@ func TestNewModelUsesConfiguredDefaultFailFilter(t *testing.T) {
 	cfg := config.Default()
 	cfg.Display.DefaultFailFilter = "exclude"
 
-	m := NewModel(cfg, repo, "", "", 10, false, "")
+	m := NewModel(cfg, repo, "", "", 10, history.SearchScope{}, "")
 
 	if got, want := m.failFilter, db.FailFilterExclude; got != want {
 		t.Fatalf("failFilter = %v, want %v", got, want)
@@ -266,3 +268,254 @@ func testNavModel(entryCount int, height int) *Model {
 		displayEntries: make([]history.ScoredEntry, entryCount),
 	}
 }
+
+func TestQualifiersFilterCandidatesAndReportErrors(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := db.NewHistoryRepo(database)
+	entries := []db.HistoryEntry{
+		{TsMs: 1000, ExitCode: 0, Command: "make build", Hostname: "laptop"},
+		{TsMs: 2000, ExitCode: 2, Command: "make test", Hostname: "laptop"},
+		{TsMs: 3000, ExitCode: 2, Command: "make lint", Hostname: "ci"},
+	}
+
+	for _, entry := range entries {
+		if _, err = repo.Insert(entry); err != nil {
+			t.Fatalf("repo.Insert(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	m := NewModel(config.Default(), repo, "", "", 10, history.SearchScope{}, "make exit:!0 host:laptop")
+	finishMatch(m)
+
+	if len(m.displayEntries) != 1 || m.displayEntries[0].Entry.Command != "make test" {
+		t.Fatalf("displayEntries = %+v, want [make test]", m.displayEntries)
+	}
+
+	m.input.SetValue("make exit:nope")
+	m.refreshQuery()
+
+	if len(m.displayEntries) != 0 || m.query.Err == nil {
+		t.Fatalf("invalid qualifier: displayEntries = %d, err = %v", len(m.displayEntries), m.query.Err)
+	}
+
+	if msg := m.emptyStateMessage(); !strings.Contains(msg, "exit:nope") {
+		t.Fatalf("emptyStateMessage() = %q, want the qualifier error", msg)
+	}
+}
+
+func TestToggleCWDCyclesDirectoryScopes(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	root := t.TempDir()
+	if err = os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatalf("Mkdir() error: %v", err)
+	}
+
+	cwd := filepath.Join(root, "pkg")
+	repo := db.NewHistoryRepo(database)
+	entries := []db.HistoryEntry{
+		{TsMs: 1000, Command: "elsewhere", Directory: t.TempDir()},
+		{TsMs: 2000, Command: "in root", Directory: root},
+		{TsMs: 3000, Command: "below cwd", Directory: filepath.Join(cwd, "api")},
+		{TsMs: 4000, Command: "in cwd", Directory: cwd},
+	}
+
+	for _, entry := range entries {
+		if _, err = repo.Insert(entry); err != nil {
+			t.Fatalf("repo.Insert(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	m := NewModel(config.Default(), repo, cwd, "", 10, history.SearchScope{}, "")
+
+	for _, want := range []struct {
+		scope history.Scope
+		count int
+	}{
+		{history.ScopeGlobal, 4},
+		{history.ScopeRepo, 3},
+		{history.ScopeSubtree, 2},
+		{history.ScopeExact, 1},
+		{history.ScopeGlobal, 4},
+	} {
+		if m.scope.Directory != want.scope || len(m.allEntries) != want.count {
+			t.Fatalf("scope = %v with %d entries, want %v with %d", m.scope.Directory, len(m.allEntries), want.scope, want.count)
+		}
+
+		m.handleToggle(tea.KeyMsg{Type: tea.KeyCtrlD})
+	}
+}
+
+func TestSessionAndHostScopesAndHostColumn(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+
+	defer func() { _ = database.Close() }()
+
+	repo := db.NewHistoryRepo(database)
+	entries := []db.HistoryEntry{
+		{TsMs: 1000, Command: "deploy", SessionID: "s2", Hostname: "build-server"},
+		{TsMs: 2000, Command: "make", SessionID: "s1", Hostname: "laptop"},
+		{TsMs: 3000, Command: "ls", SessionID: "s3", Hostname: "laptop"},
+	}
+
+	for _, entry := range entries {
+		if _, err = repo.Insert(entry); err != nil {
+			t.Fatalf("repo.Insert(%q) error: %v", entry.Command, err)
+		}
+	}
+
+	cfg := config.Default()
+	cfg.Display.DefaultScope = "session"
+
+	scope, _ := history.ParseSearchScope(cfg.Display.DefaultScope)
+	m := NewModel(cfg, repo, "", "", 10, scope, "")
+	m.SetSearchContext("s1", "laptop", "")
+
+	if len(m.allEntries) != 1 || m.allEntries[0].Command != "make" {
+		t.Fatalf("session scope entries = %+v, want [make]", m.allEntries)
+	}
+
+	if m.calcResultLayout().showHost {
+		t.Fatal("host column shown in session scope")
+	}
+
+	m.handleToggle(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s"), Alt: true})
+
+	if len(m.allEntries) != 3 {
+		t.Fatalf("global entries = %d, want 3", len(m.allEntries))
+	}
+
+	if layout := m.calcResultLayout(); !layout.showHost || layout.hostWidth != len("build-server") {
+		t.Fatalf("layout = %+v, want host column %d wide", layout, len("build-server"))
+	}
+
+	m.handleToggle(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h"), Alt: true})
+
+	if len(m.allEntries) != 2 || m.calcResultLayout().showHost {
+		t.Fatalf("host scope entries = %+v, want 2 without host column", m.allEntries)
+	}
+
+	m.handleToggle(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h"), Alt: true})
+	m.scope.Directory = history.ScopeRepo
+
+	if m.calcResultLayout().showHost {
+		t.Fatal("host column shown in repo scope")
+	}
+}
+
+// finishMatch runs the queued background match and applies its result.
+func finishMatch(m *Model) {
+	if cmd := m.takeMatchCmd(); cmd != nil {
+		if msg := cmd(); msg != nil {
+			m.Update(msg)
+		}
+	}
+}
+
+func TestMatchingNarrowsAndDiscardsStaleResults(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	entries := []db.HistoryEntry{
+		{Command: "git commit -m fix"},
+		{Command: "git checkout main"},
+		{Command: "go test ./..."},
+	}
+
+	m := &Model{cfg: cfg, allEntries: entries, input: textinput.New()}
+	for _, e := range entries {
+		m.candidates = append(m.candidates, e.Command)
+	}
+
+	m.query = history.Query{Text: "git"}
+	m.updateMatches()
+
+	stale := m.takeMatchCmd()
+
+	m.query = history.Query{Text: "git comm"}
+	m.updateMatches()
+
+	if msg := stale(); msg != nil {
+		m.Update(msg)
+	}
+
+	if len(m.displayEntries) != 0 {
+		t.Fatalf("stale result applied: %d entries", len(m.displayEntries))
+	}
+
+	finishMatch(m)
+
+	if len(m.displayEntries) != 1 || m.displayEntries[0].Entry.Command != "git commit -m fix" {
+		t.Fatalf("displayEntries = %+v, want [git commit -m fix]", m.displayEntries)
+	}
+
+	m.candidates[2] = "git commit --amend"
+	m.allEntries[2].Command = "git commit --amend"
+	m.query = history.Query{Text: "git commi"}
+	m.updateMatches()
+	finishMatch(m)
+
+	if len(m.displayEntries) != 1 {
+		t.Fatalf("narrowed match searched %d entries, want only the previous result", len(m.displayEntries))
+	}
+}
+
+func TestAcceptWaitsForPendingMatch(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	entries := []db.HistoryEntry{
+		{Command: "ls -la"},
+		{Command: "go test ./..."},
+	}
+
+	m := &Model{cfg: cfg, allEntries: entries, input: textinput.New()}
+	for _, e := range entries {
+		m.candidates = append(m.candidates, e.Command)
+	}
+
+	m.input.Focus()
+	m.updateMatches()
+
+	if len(m.displayEntries) != 2 {
+		t.Fatalf("displayEntries = %+v, want every entry for the empty query", m.displayEntries)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("go test")})
+
+	if !m.matching {
+		t.Fatal("matching = false, want the match for the typed query in flight")
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if got, want := m.Selected(), "go test ./..."; got != want {
+		t.Fatalf("Selected() = %q, want %q", got, want)
+	}
+}
@@ -31,6 +31,7 @@ type Styles struct {
 	SelectionBar    lipgloss.Style
 	SelectedCmd     lipgloss.Style
 	Cmd             lipgloss.Style
+	Qualifier       lipgloss.Style
 }
 
 func NewStyles(theme config.ThemeConfig) Styles {
@@ -144,6 +145,8 @@ func NewStyles(theme config.ThemeConfig) Styles {
 			Bold(true),
 		Cmd: lipgloss.NewStyle().
 			Foreground(lipgloss.Color("250")),
+		Qualifier: lipgloss.NewStyle().
+			Foreground(lipgloss.Color("13")),
 	}
 }
 
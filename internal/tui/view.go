@@ -24,6 +24,7 @@ const (
 	previewPaneHeight    = 4
 	defaultSelectionChar = "▌ "
 	failIncludeIndicator = "214"
+	hostColumnMaxWidth   = 16
 )
 
 type toggleIndicator struct {
@@ -32,6 +33,19 @@ type toggleIndicator struct {
 	active bool
 }
 
+// caseToggleIndicator highlights case handling that differs from smart case.
+func caseToggleIndicator(mode match.CaseMode) toggleIndicator {
+	switch mode {
+	case match.CaseSensitive:
+		return toggleIndicator{label: "Aa", bg: "13", active: true}
+	case match.CaseInsensitive:
+		return toggleIndicator{label: "aa", bg: "13", active: true}
+	case match.CaseSmart:
+	}
+
+	return toggleIndicator{label: "Aa", bg: "", active: false}
+}
+
 func failToggleIndicator(mode db.FailFilterMode) toggleIndicator {
 	indicator := toggleIndicator{label: "fails"}
 
@@ -101,6 +115,7 @@ func (m *Model) renderIndicators() string {
 
 	modes := []modeIndicator{
 		{match.ModeFuzzy, "fuzzy", "39", m.cfg.Display.EnableFuzzy},
+		{match.ModeSubstring, "substr", "48", m.cfg.Display.EnableSubstring},
 		{match.ModeGlob, "glob", "207", m.cfg.Display.EnableGlob},
 		{match.ModeRegex, "regex", "208", m.cfg.Display.EnableRegex},
 	}
@@ -122,7 +137,10 @@ func (m *Model) renderIndicators() string {
 	}
 
 	toggles := []toggleIndicator{
-		{"cwd", "10", m.cwdMode},
+		{m.scope.Directory.String(), "10", m.scope.Directory != history.ScopeGlobal},
+		{"session", "12", m.scope.Session && m.sessionID != ""},
+		{"host", "14", m.scope.Host && m.hostname != ""},
+		caseToggleIndicator(m.caseMode),
 		failToggleIndicator(m.failFilter),
 		{"dedup", "11", m.dedupe},
 	}
@@ -200,7 +218,7 @@ func (m *Model) renderInputBar() string {
 	origWidth := m.input.Width
 	inputWidth := remaining
 	m.input.Width = inputWidth
-	input := m.input.View()
+	input := m.inputView()
 	m.input.Width = origWidth
 
 	leftContent := prompt + input
@@ -215,7 +233,7 @@ func (m *Model) renderInputBar() string {
 func (m *Model) renderInput() string {
 	width := m.getWidth()
 	prompt := m.styles.Prompt.Render(m.cfg.Theme.Prompt)
-	input := m.input.View()
+	input := m.inputView()
 
 	contentWidth := lipgloss.Width(prompt) + lipgloss.Width(input)
 	padding := max(width-contentWidth, 0)
@@ -225,10 +243,60 @@ func (m *Model) renderInput() string {
 	return m.styles.Input.Width(width).Render(line)
 }
 
+// inputView renders the query input with field qualifiers styled. Values
+// too long for the input fall back to textinput's own view, which scrolls.
+func (m *Model) inputView() string {
+	value := m.input.Value()
+	if len(m.query.Qualifiers) == 0 || lipgloss.Width(value) >= m.input.Width {
+		return m.input.View()
+	}
+
+	runes := []rune(value)
+	styles := make([]lipgloss.Style, len(runes))
+
+	for i := range styles {
+		styles[i] = m.input.TextStyle
+	}
+
+	for _, span := range m.query.Qualifiers {
+		style := m.styles.Qualifier
+		if span.Err != nil {
+			style = m.styles.ExitFail
+		}
+
+		for i := span.Start; i < span.End && i < len(runes); i++ {
+			styles[i] = style
+		}
+	}
+
+	var b strings.Builder
+
+	pos := m.input.Position()
+	for i, r := range runes {
+		if i == pos {
+			m.input.Cursor.SetChar(string(r))
+			b.WriteString(m.input.Cursor.View())
+
+			continue
+		}
+
+		b.WriteString(styles[i].Render(string(r)))
+	}
+
+	if pos >= len(runes) {
+		m.input.Cursor.SetChar(" ")
+		b.WriteString(m.input.Cursor.View())
+	}
+
+	return b.String()
+}
+
 func (m *Model) emptyStateMessage() string {
 	switch {
 	case m.dbError != nil:
 		return m.styles.ExitFail.Render("  Error: " + m.dbError.Error())
+	case m.query.Err != nil:
+		return m.styles.ExitFail.Render("  " + m.query.Err.Error())
 	case m.input.Value() == "":
 		return m.styles.Dimmed.Render("  No history entries found")
 	default:
@@ -312,13 +380,38 @@ type resultLayout struct {
 	exitWidth   int
 	durWidth    int
 	timeWidth   int
+	hostWidth   int
 	dirWidth    int
 	cmdWidth    int
 	sep         string
 	barChar     string
+	showHost    bool
 	showDir     bool
 }
 
+// showHostColumn reports whether results need a host column: they span
+// several hosts and the scope is global, without directory, session or host
+// limits.
+func (m *Model) showHostColumn() bool {
+	return m.multiHost && m.scope.Directory == history.ScopeGlobal && !m.scope.Session && !m.scope.Host
+}
+
+// trailingColumns renders the optional host and directory columns that
+// follow the command.
+func (m *Model) trailingColumns(entry db.HistoryEntry, layout resultLayout, style lipgloss.Style) []string {
+	var columns []string
+
+	if layout.showHost {
+		columns = append(columns, style.Width(layout.hostWidth).Align(lipgloss.Right).Render(trimToWidth(entry.Hostname, layout.hostWidth)))
+	}
+
+	if layout.showDir {
+		columns = append(columns, style.Width(layout.dirWidth).Align(lipgloss.Right).Render(formatDirectory(entry.Directory, layout.dirWidth, m.homeDir)))
+	}
+
+	return columns
+}
+
 func (m *Model) calcResultLayout() resultLayout {
 	width := m.getWidth()
 
@@ -338,11 +431,22 @@ func (m *Model) calcResultLayout() resultLayout {
 		dirWidth = dirColumnWidth(width)
 	}
 
+	showHost := m.showHostColumn()
+
+	var hostWidth int
+	if showHost {
+		hostWidth = max(m.hostWidth, len("host"))
+	}
+
 	columnsWidth := prefixWidth + exitWidth + durWidth + timeWidth + (len(sep) * 3)
 	if m.cfg.Display.ShowDirectory {
 		columnsWidth += dirWidth + len(sep)
 	}
 
+	if showHost {
+		columnsWidth += hostWidth + len(sep)
+	}
+
 	cmdWidth := width - columnsWidth
 	if cmdWidth < 10 {
 		cmdWidth = width
@@ -354,10 +458,12 @@ func (m *Model) calcResultLayout() resultLayout {
 		exitWidth:   exitWidth,
 		durWidth:    durWidth,
 		timeWidth:   timeWidth,
+		hostWidth:   hostWidth,
 		dirWidth:    dirWidth,
 		cmdWidth:    cmdWidth,
 		sep:         sep,
 		barChar:     barChar,
+		showHost:    showHost,
 		showDir:     m.cfg.Display.ShowDirectory,
 	}
 }
@@ -438,14 +544,8 @@ func (m *Model) renderResultLine(entryIdx int, isSelected bool) string {
 		styledSep = lipgloss.NewStyle().Background(selBg).Render(layout.sep)
 	}
 
-	var line string
-
-	if layout.showDir {
-		dirStyled := metaStyle.Width(layout.dirWidth).Align(lipgloss.Right).Render(formatDirectory(entry.Entry.Directory, layout.dirWidth, m.homeDir))
-		line = strings.Join([]string{exitStyled, durStyled, timeStyled, cmdStyled, dirStyled}, styledSep)
-	} else {
-		line = strings.Join([]string{exitStyled, durStyled, timeStyled, cmdStyled}, styledSep)
-	}
+	columns := append([]string{exitStyled, durStyled, timeStyled, cmdStyled}, m.trailingColumns(entry.Entry, layout, metaStyle)...)
+	line := strings.Join(columns, styledSep)
 
 	if !isSelected {
 		return strings.Repeat(" ", layout.prefixWidth) + line
@@ -514,14 +614,8 @@ func (m *Model) renderExpandedFirstLine(entry *history.ScoredEntry, layout resul
 		styledSep = lipgloss.NewStyle().Background(selBg).Render(layout.sep)
 	}
 
-	var line string
-
-	if layout.showDir {
-		dirStyled := metaStyle.Width(layout.dirWidth).Align(lipgloss.Right).Render(formatDirectory(entry.Entry.Directory, layout.dirWidth, m.homeDir))
-		line = strings.Join([]string{exitStyled, durStyled, timeStyled, cmdStyled, dirStyled}, styledSep)
-	} else {
-		line = strings.Join([]string{exitStyled, durStyled, timeStyled, cmdStyled}, styledSep)
-	}
+	columns := append([]string{exitStyled, durStyled, timeStyled, cmdStyled}, m.trailingColumns(entry.Entry, layout, metaStyle)...)
+	line := strings.Join(columns, styledSep)
 
 	prefix := m.renderSelectionPrefix(layout, fullLineBg, selBg)
 
@@ -541,6 +635,10 @@ func (m *Model) renderExpandedContinuationLine(layout resultLayout, fullLineBg b
 		metaWidth += layout.dirWidth + len(layout.sep)
 	}
 
+	if layout.showHost {
+		metaWidth += layout.hostWidth + len(layout.sep)
+	}
+
 	continuationChar := "│ "
 	if !config.BoolDefault(m.cfg.Theme.SelectionBarShow, true) {
 		continuationChar = "  "
@@ -611,7 +709,7 @@ func (m *Model) renderFooter() string {
 		{m.cfg.Keys.Accept, "select"},
 		{m.cfg.Keys.Cancel, "cancel"},
 		{m.cfg.Keys.ModeNext, "mode"},
-		{m.cfg.Keys.ToggleCWD, "cwd"},
+		{m.cfg.Keys.ToggleCWD, "scope"},
 		{m.cfg.Keys.ToggleDedupe, "dedup"},
 		{m.cfg.Keys.Help, "help"},
 	}
@@ -631,7 +729,12 @@ func (m *Model) renderFooter() string {
 	}
 
 	left := strings.Join(parts, "  ")
-	right := m.styles.HelpDesc.Render(formatMatchCountLabel(len(m.displayEntries)))
+	label := formatMatchCountLabel(len(m.displayEntries))
+	if m.matching {
+		label = "matching… " + label
+	}
+
+	right := m.styles.HelpDesc.Render(label)
 	contentWidth := max(width-lipgloss.Width(m.styles.Footer.Render("")), 0)
 	line := layoutFooterLine(left, right, contentWidth)
 
@@ -729,11 +832,15 @@ func (m *Model) renderHelp() string {
 		{m.cfg.Keys.Top + "/" + m.cfg.Keys.Bottom, "Jump to top/bottom"},
 		{m.cfg.Keys.Accept, "Accept selection"},
 		{m.cfg.Keys.Cancel, "Cancel / quit"},
-		{m.cfg.Keys.ModeNext, "Cycle match mode (fuzzy/glob/regex)"},
+		{m.cfg.Keys.ModeNext, "Cycle match mode (fuzzy/substring/glob/regex)"},
 		{m.cfg.Keys.ModeFuzzy, "Fuzzy match mode"},
 		{m.cfg.Keys.ModeGlob, "Glob match mode"},
+		{m.cfg.Keys.ModeSubstring, "Substring match mode"},
+		{m.cfg.Keys.ToggleCase, "Cycle case (smart/sensitive/insensitive)"},
 		{m.cfg.Keys.ModeRegex, "Regex match mode"},
-		{m.cfg.Keys.ToggleCWD, "Filter to current directory"},
+		{m.cfg.Keys.ToggleCWD, "Cycle directory scope (global/repo/subtree/exact)"},
+		{m.cfg.Keys.ToggleSession, "Toggle this session only"},
+		{m.cfg.Keys.ToggleHost, "Toggle this host only"},
 		{m.cfg.Keys.ToggleDedupe, "Toggle command deduplication"},
 		{m.cfg.Keys.ToggleFails, "Cycle fail filter (include/exclude/only)"},
 		{m.cfg.Keys.PreviewCommand, "Preview multiline command"},
@@ -842,49 +949,24 @@ func (m *Model) visibleResults() []int {
 }
 
 func (m *Model) renderResultsHeader() string {
-	width := m.getWidth()
-
-	barChar := m.cfg.Theme.SelectionBarChar
-	if barChar == "" {
-		barChar = defaultSelectionChar
-	}
-
-	prefixWidth := lipgloss.Width(barChar)
-	exitWidth := 4
-	durWidth := 8
-	timeWidth := timeColumnWidth(m.cfg.Display.TimeFormat)
-	sep := "  "
+	layout := m.calcResultLayout()
+	width := layout.width
 
-	var dirWidth int
-	if m.cfg.Display.ShowDirectory {
-		dirWidth = dirColumnWidth(width)
-	}
+	exit := m.styles.ColumnHeader.Width(layout.exitWidth).Align(lipgloss.Right).Render("exit")
+	dur := m.styles.ColumnHeader.Width(layout.durWidth).Align(lipgloss.Right).Render("time")
+	when := m.styles.ColumnHeader.Width(layout.timeWidth).Align(lipgloss.Right).Render("when")
+	cmd := m.styles.ColumnHeader.Width(layout.cmdWidth).Render("command")
+	columns := []string{exit, dur, when, cmd}
 
-	columnsWidth := prefixWidth + exitWidth + durWidth + timeWidth + (len(sep) * 3)
-	if m.cfg.Display.ShowDirectory {
-		columnsWidth += dirWidth + len(sep)
+	if layout.showHost {
+		columns = append(columns, m.styles.ColumnHeader.Width(layout.hostWidth).Align(lipgloss.Right).Render("host"))
 	}
 
-	cmdWidth := width - columnsWidth
-	if cmdWidth < 10 {
-		cmdWidth = width
+	if layout.showDir {
+		columns = append(columns, m.styles.ColumnHeader.Width(layout.dirWidth).Align(lipgloss.Right).Render("dir"))
 	}
 
-	exit := m.styles.ColumnHeader.Width(exitWidth).Align(lipgloss.Right).Render("exit")
-	dur := m.styles.ColumnHeader.Width(durWidth).Align(lipgloss.Right).Render("time")
-	when := m.styles.ColumnHeader.Width(timeWidth).Align(lipgloss.Right).Render("when")
-	cmd := m.styles.ColumnHeader.Width(cmdWidth).Render("command")
-
-	prefix := strings.Repeat(" ", prefixWidth)
-
-	var line string
-
-	if m.cfg.Display.ShowDirectory {
-		dir := m.styles.ColumnHeader.Width(dirWidth).Align(lipgloss.Right).Render("dir")
-		line = prefix + strings.Join([]string{exit, dur, when, cmd, dir}, sep)
-	} else {
-		line = prefix + strings.Join([]string{exit, dur, when, cmd}, sep)
-	}
+	line := strings.Repeat(" ", layout.prefixWidth) + strings.Join(columns, layout.sep)
 
 	if lipgloss.Width(line) < width {
 		line += strings.Repeat(" ", width-lipgloss.Width(line))
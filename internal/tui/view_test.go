@@ -88,7 +88,7 @@ func TestRenderFooterUsesDefaultConfiguredKeys(t *testing.T) {
 	rendered := m.renderFooter()
 	for _, needle := range []string{
 		"ctrl+d",
-		"cwd",
+		"scope",
 		"ctrl+g",
 		"dedup",
 		"ctrl+s",
@@ -122,7 +122,7 @@ func TestRenderFooterUsesRemappedKeys(t *testing.T) {
 		}
 	}
 
-	for _, needle := range []string{"ctrl+d cwd", "ctrl+g dedup", "ctrl+s mode"} {
+	for _, needle := range []string{"ctrl+d scope", "ctrl+g dedup", "ctrl+s mode"} {
 		if strings.Contains(rendered, needle) {
 			t.Fatalf("renderFooter() = %q, should not contain stale footer hint %q", rendered, needle)
 		}